@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"the-blockchain-bar/database"
+	"the-blockchain-bar/node"
+
+	"github.com/spf13/cobra"
+)
+
+func chainCmd() *cobra.Command {
+	var chainCmd = &cobra.Command{
+		Use:   "chain",
+		Short: "Inspects the blockchain (supply, ...).",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return ErrIncorrectUsage
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	chainCmd.AddCommand(chainSupplyCmd())
+
+	return chainCmd
+}
+
+func chainSupplyCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "supply",
+		Short: "Shows the TBB in circulation and the emission schedule.",
+		Run: func(cmd *cobra.Command, args []string) {
+			state, err := database.NewStateFromDisk(getDataDirFromCmd(cmd), node.DefaultMiningDifficulty)
+			if err != nil {
+				fatal(err)
+			}
+			defer state.Close()
+
+			policy := state.RewardPolicy()
+
+			fmt.Printf("Supply at block %d (%x):\n", state.LatestBlock().Header.Number, state.LatestBlockHash())
+			fmt.Println("-----------------")
+			fmt.Printf("Circulating: %s TBB\n", state.Supply())
+			fmt.Printf("Genesis: %s TBB\n", policy.GenesisSupply)
+
+			if policy.MaxSupply != nil {
+				fmt.Printf("Max: %s TBB\n", policy.MaxSupply)
+			} else {
+				fmt.Println("Max: uncapped")
+			}
+
+			fmt.Printf("Next block reward: %s TBB\n", state.NextBlockReward())
+
+			if policy.HalvingInterval != 0 {
+				fmt.Printf("Reward halving every %d blocks, from %d TBB\n", policy.HalvingInterval, policy.InitialReward)
+			} else {
+				fmt.Println("Reward halving: never")
+			}
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+
+	return cmd
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"the-blockchain-bar/database"
+
+	"github.com/spf13/cobra"
+)
+
+func dbCmd() *cobra.Command {
+	var dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Maintains the blocks database (migrate, ...).",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return ErrIncorrectUsage
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	dbCmd.AddCommand(dbMigrateCmd())
+
+	return dbCmd
+}
+
+func dbMigrateCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Converts the blocks database to another storage backend.",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir := getDataDirFromCmd(cmd)
+			to, _ := cmd.Flags().GetString(flagMigrateTo)
+
+			from := database.DetectBlockStoreBackend(dataDir)
+			fmt.Printf("Migrating blocks from '%s' to '%s' backend...\n", from, to)
+
+			if err := database.MigrateBlockStore(dataDir, to); err != nil {
+				fatal(err)
+			}
+
+			fmt.Printf("Blocks migrated. The '%s' database was kept with a .bak suffix.\n", from)
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().String(flagMigrateTo, "", "target blocks storage backend: 'file' or 'leveldb'")
+	cmd.MarkFlagRequired(flagMigrateTo)
+
+	return cmd
+}
@@ -17,6 +17,8 @@ const (
 	flagBootstrapPort = "bootstrap-port"
 	flagSSLEmail      = "ssl-email"
 	flagDisableSSL    = "disable-ssl"
+	flagDBBackend     = "db-backend"
+	flagMigrateTo     = "to"
 )
 
 var ErrIncorrectUsage = errors.New("incorrect usage of tbb command")
@@ -34,6 +36,9 @@ func main() {
 	tbbCmd.AddCommand(balancesCmd())
 	tbbCmd.AddCommand(runCmd())
 	tbbCmd.AddCommand(walletCmd())
+	tbbCmd.AddCommand(dbCmd())
+	tbbCmd.AddCommand(txCmd())
+	tbbCmd.AddCommand(chainCmd())
 
 	if err := tbbCmd.Execute(); err != nil {
 		fatal(err)
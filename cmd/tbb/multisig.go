@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"the-blockchain-bar/database"
+	"the-blockchain-bar/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagMultisigThreshold = "threshold"
+	flagMultisigOwner     = "owner"
+	flagTxIn              = "in"
+)
+
+// txMultisigCmd collects the owners' signatures of TXs sent by multisig accounts, from the TIP-11 fork on.
+// The TX is created once, passed around the owners to sign and submitted to a node's /tx/send_raw.
+func txMultisigCmd() *cobra.Command {
+	var multisigCmd = &cobra.Command{
+		Use:   "multisig",
+		Short: "Creates and signs transactions of M-of-N multisig accounts (address, new, sign, combine).",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return ErrIncorrectUsage
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	multisigCmd.AddCommand(txMultisigAddressCmd())
+	multisigCmd.AddCommand(txMultisigNewCmd())
+	multisigCmd.AddCommand(txMultisigSignCmd())
+	multisigCmd.AddCommand(txMultisigCombineCmd())
+
+	return multisigCmd
+}
+
+func txMultisigAddressCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "address",
+		Short: "Shows the address of the multisig account of M-of-N owners, to send it TBB.",
+		Run: func(cmd *cobra.Command, args []string) {
+			policy := getMultisigPolicyFromCmd(cmd)
+
+			fmt.Printf("Multisig account %d of %d: %s\n", policy.Threshold, len(policy.Owners), policy.Address().Hex())
+		},
+	}
+
+	addMultisigPolicyFlags(cmd)
+
+	return cmd
+}
+
+func txMultisigNewCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "new",
+		Short: "Creates a transaction sent by a multisig account, for its owners to sign.",
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString(flagTxOut)
+
+			policy := getMultisigPolicyFromCmd(cmd)
+
+			tx, err := database.NewMultisigTx(getTxFromCmd(cmd, policy.Address()), policy)
+			if err != nil {
+				fatal(err)
+			}
+
+			writeTxJson(tx, out, fmt.Sprintf("Multisig TX from %s", policy.Address().Hex()))
+		},
+	}
+
+	addMultisigPolicyFlags(cmd)
+	addTxFlags(cmd)
+
+	return cmd
+}
+
+func txMultisigSignCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "sign",
+		Short: "Adds the signature of a multisig account owner, from the datadir keystore, to a multisig transaction.",
+		Run: func(cmd *cobra.Command, args []string) {
+			in, _ := cmd.Flags().GetString(flagTxIn)
+			out, _ := cmd.Flags().GetString(flagTxOut)
+			account := getAccountFromCmd(cmd)
+
+			tx := readTxJson(in)
+
+			password := getPassPhrase(fmt.Sprintf("Please enter the password to decrypt the %s account:", account.Hex()), false)
+
+			key, err := wallet.DecryptKeystoreAccount(account, password, wallet.GetKeystoreDirPath(getDataDirFromCmd(cmd)))
+			if err != nil {
+				fatal(err)
+			}
+
+			tx, err = wallet.SignMultisigTx(tx, key.PrivateKey)
+			if err != nil {
+				fatal(err)
+			}
+
+			writeTxJson(tx, out, fmt.Sprintf("Multisig TX with %d of %d signatures", len(tx.Multisig.Sigs), tx.Multisig.Threshold))
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	addAccountFlag(cmd)
+	cmd.Flags().String(flagTxIn, "", "file of the multisig TX JSON to sign")
+	cmd.Flags().String(flagTxOut, "", "file to write the TX JSON to (default: stdout)")
+	cmd.MarkFlagRequired(flagTxIn)
+
+	return cmd
+}
+
+func txMultisigCombineCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "combine",
+		Short: "Merges the signatures of copies of a multisig transaction signed by different owners.",
+		Run: func(cmd *cobra.Command, args []string) {
+			in, _ := cmd.Flags().GetStringSlice(flagTxIn)
+			out, _ := cmd.Flags().GetString(flagTxOut)
+
+			txs := make([]database.SignedTx, 0, len(in))
+			for _, path := range in {
+				txs = append(txs, readTxJson(path))
+			}
+
+			tx, err := wallet.CombineMultisigTxs(txs...)
+			if err != nil {
+				fatal(err)
+			}
+
+			writeTxJson(tx, out, fmt.Sprintf("Multisig TX with %d of %d signatures", len(tx.Multisig.Sigs), tx.Multisig.Threshold))
+		},
+	}
+
+	cmd.Flags().StringSlice(flagTxIn, nil, "files of the signed multisig TX copies, repeated or comma separated")
+	cmd.Flags().String(flagTxOut, "", "file to write the TX JSON to (default: stdout)")
+	cmd.MarkFlagRequired(flagTxIn)
+
+	return cmd
+}
+
+func addMultisigPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().Uint(flagMultisigThreshold, 0, "signatures required to spend, the M of M-of-N")
+	cmd.Flags().StringSlice(flagMultisigOwner, nil, fmt.Sprintf("owner accounts, repeated or comma separated, up to %d", database.MaxMultisigOwners))
+	cmd.MarkFlagRequired(flagMultisigThreshold)
+	cmd.MarkFlagRequired(flagMultisigOwner)
+}
+
+func getMultisigPolicyFromCmd(cmd *cobra.Command) database.MultisigPolicy {
+	threshold, _ := cmd.Flags().GetUint(flagMultisigThreshold)
+	ownersRaw, _ := cmd.Flags().GetStringSlice(flagMultisigOwner)
+
+	owners := make([]common.Address, 0, len(ownersRaw))
+	for _, ownerRaw := range ownersRaw {
+		if !common.IsHexAddress(ownerRaw) {
+			fatal(fmt.Errorf("owner '%s' must be an account address", ownerRaw))
+		}
+
+		owners = append(owners, database.NewAccount(ownerRaw))
+	}
+
+	policy, err := database.NewMultisigPolicy(threshold, owners)
+	if err != nil {
+		fatal(err)
+	}
+
+	return policy
+}
+
+func readTxJson(path string) database.SignedTx {
+	txJson, err := ioutil.ReadFile(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	var tx database.SignedTx
+	if err := json.Unmarshal(txJson, &tx); err != nil {
+		fatal(fmt.Errorf("'%s' isn't a TX JSON. %s", path, err.Error()))
+	}
+
+	return tx
+}
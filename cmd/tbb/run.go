@@ -23,9 +23,14 @@ func runCmd() *cobra.Command {
 			bootstrapIp, _ := cmd.Flags().GetString(flagBootstrapIp)
 			bootstrapPort, _ := cmd.Flags().GetUint64(flagBootstrapPort)
 			bootstrapAcc, _ := cmd.Flags().GetString(flagBootstrapAcc)
+			dbBackend, _ := cmd.Flags().GetString(flagDBBackend)
 
 			fmt.Println("Launching TBB node and its HTTP API...")
 
+			if err := database.ConfigureBlockStore(getDataDirFromCmd(cmd), dbBackend); err != nil {
+				fatal(err)
+			}
+
 			bootstrap := node.NewPeerNode(
 				bootstrapIp,
 				bootstrapPort,
@@ -66,6 +71,7 @@ func runCmd() *cobra.Command {
 	runCmd.Flags().String(flagBootstrapIp, node.DefaultBootstrapIp, "default bootstrap server to interconnect peers")
 	runCmd.Flags().Uint64(flagBootstrapPort, node.HttpSSLPort, "default bootstrap server port to interconnect peers")
 	runCmd.Flags().String(flagBootstrapAcc, node.DefaultBootstrapAcc, "default bootstrap w/ 1M TBB tokens Genesis account")
+	runCmd.Flags().String(flagDBBackend, "", "blocks storage backend: 'file' or 'leveldb' (default: the one already used by the datadir, 'file' for a new one)")
 
 	return runCmd
 }
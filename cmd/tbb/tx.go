@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"the-blockchain-bar/database"
+	"the-blockchain-bar/node"
+	"the-blockchain-bar/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagTxHash     = "hash"
+	flagTxFrom     = "from"
+	flagTxTo       = "to"
+	flagTxValue    = "value"
+	flagTxNonce    = "nonce"
+	flagTxGas      = "gas"
+	flagTxGasPrice = "gas-price"
+	flagTxData     = "data"
+	flagTxChainID  = "chain-id"
+	flagTxOut      = "out"
+)
+
+func txCmd() *cobra.Command {
+	var txCmd = &cobra.Command{
+		Use:   "tx",
+		Short: "Interact with transactions (show, sign, ...).",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return ErrIncorrectUsage
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	txCmd.AddCommand(txShowCmd())
+	txCmd.AddCommand(txSignCmd())
+	txCmd.AddCommand(txMultisigCmd())
+
+	return txCmd
+}
+
+func txShowCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "show",
+		Short: "Shows a mined transaction and the block including it.",
+		Run: func(cmd *cobra.Command, args []string) {
+			hashRaw, _ := cmd.Flags().GetString(flagTxHash)
+
+			txHash := database.Hash{}
+			if err := txHash.UnmarshalText([]byte(hashRaw)); err != nil {
+				fatal(err)
+			}
+
+			state, err := database.NewStateFromDisk(getDataDirFromCmd(cmd), node.DefaultMiningDifficulty)
+			if err != nil {
+				fatal(err)
+			}
+			defer state.Close()
+
+			tx, location, err := state.GetTx(txHash)
+			if err != nil {
+				fatal(err)
+			}
+
+			txJson, err := json.MarshalIndent(tx, "", "  ")
+			if err != nil {
+				fatal(err)
+			}
+
+			fmt.Printf("TX %x\n", location.TxHash)
+			fmt.Printf("Block: %d (%x), index %d\n", location.BlockNumber, location.BlockHash, location.Index)
+			fmt.Println("-----------------")
+			fmt.Println(string(txJson))
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().String(flagTxHash, "", "hash of the transaction to show")
+	cmd.MarkFlagRequired(flagTxHash)
+
+	return cmd
+}
+
+// txSignCmd signs a TX offline with a local keystore account, the resulting JSON is submitted to a node's /tx/send_raw.
+func txSignCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "sign",
+		Short: "Signs a transaction offline with a keystore account, for the /tx/send_raw endpoint.",
+		Run: func(cmd *cobra.Command, args []string) {
+			fromRaw, _ := cmd.Flags().GetString(flagTxFrom)
+			out, _ := cmd.Flags().GetString(flagTxOut)
+
+			if !common.IsHexAddress(fromRaw) {
+				fatal(fmt.Errorf("'%s' must be an account address", fromRaw))
+			}
+
+			from := database.NewAccount(fromRaw)
+			tx := getTxFromCmd(cmd, from)
+
+			password := getPassPhrase(fmt.Sprintf("Please enter the password to decrypt the %s account:", from.Hex()), false)
+
+			key, err := wallet.DecryptKeystoreAccount(from, password, wallet.GetKeystoreDirPath(getDataDirFromCmd(cmd)))
+			if err != nil {
+				fatal(err)
+			}
+
+			// TXs are signed for a network from the TIP-8 fork on, without chain ID for networks prior to it
+			var signedTx database.SignedTx
+			if tx.ChainID != "" {
+				signedTx, err = wallet.SignTx(tx, key.PrivateKey)
+			} else {
+				signedTx, err = wallet.SignLegacyTx(tx, key.PrivateKey)
+			}
+
+			if err != nil {
+				fatal(err)
+			}
+
+			writeTxJson(signedTx, out, "Signed TX")
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().String(flagTxFrom, "", "sender account, from the datadir keystore")
+	addTxFlags(cmd)
+	cmd.MarkFlagRequired(flagTxFrom)
+
+	return cmd
+}
+
+// addTxFlags adds the flags of the TX attributes but its sender, read with getTxFromCmd.
+func addTxFlags(cmd *cobra.Command) {
+	cmd.Flags().String(flagTxTo, "", "receiver account")
+	cmd.Flags().String(flagTxValue, "0", "TBB to send")
+	cmd.Flags().Uint(flagTxNonce, 0, "sender's next nonce, its latest TX nonce + 1")
+	cmd.Flags().Uint(flagTxGas, database.TxGas, "gas of the TX, 0 for networks prior to TIP-1")
+	cmd.Flags().Uint(flagTxGasPrice, database.TxGasPriceDefault, "gas price of the TX, 0 for networks prior to TIP-1")
+	cmd.Flags().String(flagTxData, "", "TX data")
+	cmd.Flags().String(flagTxChainID, "", "chain ID of the network, from its genesis. leave empty for networks prior to TIP-8")
+	cmd.Flags().String(flagTxOut, "", "file to write the TX JSON to (default: stdout)")
+	cmd.MarkFlagRequired(flagTxTo)
+	cmd.MarkFlagRequired(flagTxNonce)
+}
+
+func getTxFromCmd(cmd *cobra.Command, from common.Address) database.Tx {
+	toRaw, _ := cmd.Flags().GetString(flagTxTo)
+	valueRaw, _ := cmd.Flags().GetString(flagTxValue)
+	nonce, _ := cmd.Flags().GetUint(flagTxNonce)
+	gas, _ := cmd.Flags().GetUint(flagTxGas)
+	gasPrice, _ := cmd.Flags().GetUint(flagTxGasPrice)
+	data, _ := cmd.Flags().GetString(flagTxData)
+	chainID, _ := cmd.Flags().GetString(flagTxChainID)
+
+	if !common.IsHexAddress(toRaw) {
+		fatal(fmt.Errorf("'%s' must be an account address", toRaw))
+	}
+
+	value, ok := new(big.Int).SetString(valueRaw, 10)
+	if !ok || value.Sign() < 0 {
+		fatal(fmt.Errorf("value '%s' must be a non-negative integer", valueRaw))
+	}
+
+	tx := database.NewTx(from, database.NewAccount(toRaw), value, nonce, new(big.Int).SetUint64(uint64(gas)), new(big.Int).SetUint64(uint64(gasPrice)), data)
+	tx.ChainID = chainID
+
+	return tx
+}
+
+// writeTxJson writes the TX JSON to the out file, or stdout if empty.
+func writeTxJson(tx database.SignedTx, out, description string) {
+	txJson, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+
+	if out == "" {
+		fmt.Println(string(txJson))
+
+		return
+	}
+
+	if err := ioutil.WriteFile(out, txJson, 0600); err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("%s written to %s\n", description, out)
+}
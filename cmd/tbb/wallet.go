@@ -1,10 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"the-blockchain-bar/database"
+	"the-blockchain-bar/node"
 	"the-blockchain-bar/wallet"
 
+	"github.com/ethereum/go-ethereum/common"
+
 	"github.com/ethereum/go-ethereum/console/prompt"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -12,6 +18,17 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	flagAccount        = "account"
+	flagPrivateKeyFile = "private-key-file"
+	flagKeystoreFile   = "keystore-file"
+	flagOut            = "out"
+	flagWords          = "words"
+	flagIndex          = "index"
+	flagCount          = "count"
+	flagPassphrase     = "passphrase"
+)
+
 func walletCmd() *cobra.Command {
 	var walletCmd = &cobra.Command{
 		Use:   "wallet",
@@ -24,6 +41,13 @@ func walletCmd() *cobra.Command {
 	}
 
 	walletCmd.AddCommand(walletNewAccountCmd())
+	walletCmd.AddCommand(walletListCmd())
+	walletCmd.AddCommand(walletImportCmd())
+	walletCmd.AddCommand(walletExportCmd())
+	walletCmd.AddCommand(walletPasswdCmd())
+	walletCmd.AddCommand(walletInspectCmd())
+	walletCmd.AddCommand(walletNewMnemonicCmd())
+	walletCmd.AddCommand(walletDeriveCmd())
 
 	return walletCmd
 }
@@ -52,6 +76,246 @@ func walletNewAccountCmd() *cobra.Command {
 	return cmd
 }
 
+func walletListCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "list",
+		Short: "Lists the keystore accounts with their balances from the local state.",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir := getDataDirFromCmd(cmd)
+
+			state, err := database.NewStateFromDisk(dataDir, node.DefaultMiningDifficulty)
+			if err != nil {
+				fatal(err)
+			}
+			defer state.Close()
+
+			fmt.Printf("Keystore accounts balances at %x:\n", state.LatestBlockHash())
+			fmt.Println("-----------------")
+
+			for _, account := range wallet.ListKeystoreAccounts(dataDir) {
+				fmt.Printf("%s: %s TBB\n", account.Hex(), state.Balance(account))
+			}
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+
+	return cmd
+}
+
+func walletImportCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "import",
+		Short: "Imports a raw hex private key or a geth keystore JSON into the keystore.",
+		Run: func(cmd *cobra.Command, args []string) {
+			dataDir := getDataDirFromCmd(cmd)
+			privateKeyFile, _ := cmd.Flags().GetString(flagPrivateKeyFile)
+			keystoreFile, _ := cmd.Flags().GetString(flagKeystoreFile)
+
+			if (privateKeyFile == "") == (keystoreFile == "") {
+				fatal(fmt.Errorf("%w. either --%s or --%s is required", ErrIncorrectUsage, flagPrivateKeyFile, flagKeystoreFile))
+			}
+
+			var account common.Address
+			if privateKeyFile != "" {
+				hexKey, err := ioutil.ReadFile(privateKeyFile)
+				if err != nil {
+					fatal(err)
+				}
+
+				password := getPassPhrase("Please enter a password to encrypt the imported key:", true)
+
+				account, err = wallet.ImportPrivateKey(dataDir, string(hexKey), password)
+				if err != nil {
+					fatal(err)
+				}
+			} else {
+				keyJson, err := ioutil.ReadFile(keystoreFile)
+				if err != nil {
+					fatal(err)
+				}
+
+				password := getPassPhrase("Please enter the password of the keystore file:", false)
+				newPassword := getPassPhrase("Please enter a password to encrypt the imported key:", true)
+
+				account, err = wallet.ImportKeystore(dataDir, keyJson, password, newPassword)
+				if err != nil {
+					fatal(err)
+				}
+			}
+
+			fmt.Printf("Account imported: %s\n", account.Hex())
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().String(flagPrivateKeyFile, "", "file containing the hex encoded private key to import")
+	cmd.Flags().String(flagKeystoreFile, "", "geth keystore JSON file to import")
+
+	return cmd
+}
+
+func walletExportCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "export",
+		Short: "Exports a keystore account into an encrypted keystore file.",
+		Run: func(cmd *cobra.Command, args []string) {
+			account := getAccountFromCmd(cmd)
+			out, _ := cmd.Flags().GetString(flagOut)
+
+			password := getPassPhrase(fmt.Sprintf("Please enter the password to decrypt the %s account:", account.Hex()), false)
+			newPassword := getPassPhrase("Please enter a password to encrypt the exported keystore file:", true)
+
+			keyJson, err := wallet.ExportKeystoreAccount(getDataDirFromCmd(cmd), account, password, newPassword)
+			if err != nil {
+				fatal(err)
+			}
+
+			if err := ioutil.WriteFile(out, keyJson, 0600); err != nil {
+				fatal(err)
+			}
+
+			fmt.Printf("Account %s exported to %s\n", account.Hex(), out)
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	addAccountFlag(cmd)
+	cmd.Flags().String(flagOut, "", "file to write the keystore JSON to")
+	cmd.MarkFlagRequired(flagOut)
+
+	return cmd
+}
+
+func walletPasswdCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "passwd",
+		Short: "Re-encrypts a keystore account with a new password.",
+		Run: func(cmd *cobra.Command, args []string) {
+			account := getAccountFromCmd(cmd)
+
+			password := getPassPhrase(fmt.Sprintf("Please enter the current password of the %s account:", account.Hex()), false)
+			newPassword := getPassPhrase("Please enter the new password:", true)
+
+			if err := wallet.ChangeKeystorePassword(getDataDirFromCmd(cmd), account, password, newPassword); err != nil {
+				fatal(err)
+			}
+
+			fmt.Printf("Password of account %s changed\n", account.Hex())
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	addAccountFlag(cmd)
+
+	return cmd
+}
+
+func walletInspectCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "inspect",
+		Short: "Shows the address and public key of a keystore account.",
+		Run: func(cmd *cobra.Command, args []string) {
+			account := getAccountFromCmd(cmd)
+
+			password := getPassPhrase(fmt.Sprintf("Please enter the password to decrypt the %s account:", account.Hex()), false)
+
+			key, err := wallet.DecryptKeystoreAccount(account, password, wallet.GetKeystoreDirPath(getDataDirFromCmd(cmd)))
+			if err != nil {
+				fatal(err)
+			}
+
+			fmt.Printf("Address: %s\n", key.Address.Hex())
+			fmt.Printf("Public key: %s\n", wallet.PublicKeyHex(key.PrivateKey))
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	addAccountFlag(cmd)
+
+	return cmd
+}
+
+func walletNewMnemonicCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "new-mnemonic",
+		Short: "Generates a new BIP-39 mnemonic to derive accounts from.",
+		Run: func(cmd *cobra.Command, args []string) {
+			words, _ := cmd.Flags().GetInt(flagWords)
+
+			mnemonic, err := wallet.NewMnemonic(words)
+			if err != nil {
+				fatal(err)
+			}
+
+			fmt.Println("Write down the mnemonic and keep it safe, anyone knowing it can spend from all the derived accounts:")
+			fmt.Println(mnemonic)
+		},
+	}
+
+	cmd.Flags().Int(flagWords, 12, "number of words of the mnemonic, 12 to 24")
+
+	return cmd
+}
+
+func walletDeriveCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "derive",
+		Short: fmt.Sprintf("Derives accounts from a BIP-39 mnemonic, along %s with the account index last, into the keystore.", wallet.HDBaseDerivationPath.String()),
+		Run: func(cmd *cobra.Command, args []string) {
+			index, _ := cmd.Flags().GetUint32(flagIndex)
+			count, _ := cmd.Flags().GetUint32(flagCount)
+			withPassphrase, _ := cmd.Flags().GetBool(flagPassphrase)
+
+			if err := wallet.ValidateHDAccountRange(index, count); err != nil {
+				fatal(err)
+			}
+
+			mnemonic, err := prompt.Stdin.PromptPassword("Mnemonic: ")
+			if err != nil {
+				utils.Fatalf("Failed to read the mnemonic: %v", err)
+			}
+
+			passphrase := ""
+			if withPassphrase {
+				passphrase = getPassPhrase("Please enter the BIP-39 passphrase of the mnemonic:", false)
+			}
+
+			password := getPassPhrase("Please enter a password to encrypt the derived accounts:", true)
+
+			accounts, err := wallet.DeriveKeystoreAccounts(getDataDirFromCmd(cmd), mnemonic, passphrase, password, index, count)
+			if err != nil {
+				fatal(err)
+			}
+
+			for i, account := range accounts {
+				fmt.Printf("%s: %s\n", wallet.HDDerivationPath(index+uint32(i)).String(), account.Hex())
+			}
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().Uint32(flagIndex, 0, "index of the first account to derive")
+	cmd.Flags().Uint32(flagCount, 1, "number of accounts to derive")
+	cmd.Flags().Bool(flagPassphrase, false, "prompt for the BIP-39 passphrase protecting the mnemonic")
+
+	return cmd
+}
+
+func addAccountFlag(cmd *cobra.Command) {
+	cmd.Flags().String(flagAccount, "", "keystore account address")
+	cmd.MarkFlagRequired(flagAccount)
+}
+
+func getAccountFromCmd(cmd *cobra.Command) common.Address {
+	account, _ := cmd.Flags().GetString(flagAccount)
+	if !common.IsHexAddress(account) {
+		fatal(errors.New("--account must be an account address"))
+	}
+
+	return database.NewAccount(account)
+}
+
 func getPassPhrase(inputPrompt string, confirmation bool) string {
 	fmt.Println(inputPrompt)
 
@@ -3,37 +3,47 @@ package database
 import (
 	"crypto/sha256"
 	"encoding/json"
-	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
-const BlockReward = 100
-
 type Block struct {
 	Header BlockHeader `json:"header"`  // metadata (parent block hash + timestamp)
 	TXs    []SignedTx  `json:"payload"` // new transactions only (payload)
 }
 
 type BlockHeader struct {
-	Parent Hash           `json:"parent"` // parent block reference
-	Number uint64         `json:"number"`
-	Nonce  uint32         `json:"nonce"`
-	Time   uint64         `json:"time"`
-	Miner  common.Address `json:"miner"`
+	Parent     Hash           `json:"parent"` // parent block reference
+	Number     uint64         `json:"number"`
+	Nonce      uint32         `json:"nonce"`
+	Time       uint64         `json:"time"`
+	Miner      common.Address `json:"miner"`
+	TxRoot     *Hash          `json:"tx_root,omitempty"`    // Merkle root of the TXs, from the TIP-2 fork on
+	StateRoot  *Hash          `json:"state_root,omitempty"` // root of the accounts state after the block, from the TIP-3 fork on
+	Difficulty uint           `json:"difficulty,omitempty"` // mining difficulty, from the TIP-4 fork until TIP-5
+	Bits       uint32         `json:"bits,omitempty"`       // compact mining target, from the TIP-5 fork on
 }
 
 type BlockFS struct {
-	Key   Hash  `json:"hash"`
-	Value Block `json:"block"`
+	Key      Hash      `json:"hash"`
+	Value    Block     `json:"block"`
+	Receipts []Receipt `json:"receipts,omitempty"` // in the TXs order, none for blocks persisted prior to receipts
 }
 
 func NewBlock(parent Hash, number uint64, nonce uint32, time uint64, miner common.Address, txs []SignedTx) Block {
-	return Block{BlockHeader{parent, number, nonce, time, miner}, txs}
+	return Block{BlockHeader{Parent: parent, Number: number, Nonce: nonce, Time: time, Miner: miner}, txs}
 }
 
+// Hash of the whole block JSON, or of its header only once the header commits to the TXs through their Merkle root.
 func (b Block) Hash() (hash Hash, err error) {
-	blockJson, err := json.Marshal(b)
+	var blockJson []byte
+	if b.Header.TxRoot != nil {
+		blockJson, err = json.Marshal(b.Header)
+	} else {
+		blockJson, err = json.Marshal(b)
+	}
+
 	if err != nil {
 		return hash, err
 	}
@@ -41,28 +51,123 @@ func (b Block) Hash() (hash Hash, err error) {
 	return sha256.Sum256(blockJson), nil
 }
 
-func (b Block) GasReward() uint {
-	reward := uint(0)
+// CommitTXs sets the Merkle root of the block TXs in its header.
+func (b *Block) CommitTXs() error {
+	txRoot, err := TxRoot(b.TXs)
+	if err != nil {
+		return err
+	}
+
+	b.Header.TxRoot = &txRoot
+
+	return nil
+}
+
+func (b Block) validateTxRoot(isTIP2Fork bool) error {
+	if !isTIP2Fork {
+		if b.Header.TxRoot != nil {
+			return newBlockError(b.Header.Number, ErrBadTxRoot, "block '%d' can't have a TX root before the TIP-2 fork", b.Header.Number)
+		}
+
+		return nil
+	}
+
+	if b.Header.TxRoot == nil {
+		return newBlockError(b.Header.Number, ErrBadTxRoot, "block '%d' is missing the TX root required from the TIP-2 fork on", b.Header.Number)
+	}
+
+	txRoot, err := TxRoot(b.TXs)
+	if err != nil {
+		return err
+	}
+
+	if txRoot != *b.Header.TxRoot {
+		return newBlockMismatchError(b.Header.Number, ErrBadTxRoot, txRoot, *b.Header.TxRoot, "block '%d' TX root must be '%x' not '%x'", b.Header.Number, txRoot, *b.Header.TxRoot)
+	}
+
+	return nil
+}
+
+func (b Block) GasReward() *big.Int {
+	reward := big.NewInt(0)
 
 	for _, tx := range b.TXs {
-		reward += tx.GasCost()
+		reward.Add(reward, tx.GasCost())
 	}
 
 	return reward
 }
 
-func IsBlockHashValid(hash Hash, miningDifficulty uint) bool {
-	zeroesCount := uint(0)
+func (b Block) validateDifficulty(difficulty uint, isTIP4Fork bool) error {
+	if b.Header.Bits != 0 {
+		return newBlockError(b.Header.Number, ErrBadDifficulty, "block '%d' can't have a target before the TIP-5 fork", b.Header.Number)
+	}
 
-	for i := uint(0); i < miningDifficulty; i++ {
-		if fmt.Sprintf("%x", hash[i]) == "0" {
-			zeroesCount++
+	if !isTIP4Fork {
+		if b.Header.Difficulty != 0 {
+			return newBlockError(b.Header.Number, ErrBadDifficulty, "block '%d' can't have a difficulty before the TIP-4 fork", b.Header.Number)
 		}
+
+		return nil
 	}
 
-	if fmt.Sprintf("%x", hash[miningDifficulty]) == "0" {
+	if b.Header.Difficulty != difficulty {
+		return newBlockMismatchError(b.Header.Number, ErrBadDifficulty, difficulty, b.Header.Difficulty, "block '%d' difficulty must be '%d' not '%d'", b.Header.Number, difficulty, b.Header.Difficulty)
+	}
+
+	return nil
+}
+
+func (b Block) validateBits(bits uint32) error {
+	if b.Header.Difficulty != 0 {
+		return newBlockError(b.Header.Number, ErrBadDifficulty, "block '%d' can't have a difficulty from the TIP-5 fork on, its target is in the bits", b.Header.Number)
+	}
+
+	if b.Header.Bits != bits {
+		return newBlockMismatchError(b.Header.Number, ErrBadDifficulty, bits, b.Header.Bits, "block '%d' bits must be '%08x' not '%08x'", b.Header.Number, bits, b.Header.Bits)
+	}
+
+	return nil
+}
+
+func (b Block) validateStateRoot(stateRoot Hash, isTIP3Fork bool) error {
+	if !isTIP3Fork {
+		if b.Header.StateRoot != nil {
+			return newBlockError(b.Header.Number, ErrBadStateRoot, "block '%d' can't have a state root before the TIP-3 fork", b.Header.Number)
+		}
+
+		return nil
+	}
+
+	if b.Header.StateRoot == nil {
+		return newBlockError(b.Header.Number, ErrBadStateRoot, "block '%d' is missing the state root required from the TIP-3 fork on", b.Header.Number)
+	}
+
+	if stateRoot != *b.Header.StateRoot {
+		return newBlockMismatchError(b.Header.Number, ErrBadStateRoot, stateRoot, *b.Header.StateRoot, "block '%d' state root must be '%x' not '%x'. the state computed by this node diverges from the miner's", b.Header.Number, stateRoot, *b.Header.StateRoot)
+	}
+
+	return nil
+}
+
+// BlockWork is the proof-of-work a block of the given difficulty represents, i.e. 256^difficulty hashes on average.
+// The main chain is the one with the most cumulative work, not the longest one.
+func BlockWork(miningDifficulty uint) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), 8*miningDifficulty)
+}
+
+// IsBlockHashValid checks the legacy difficulty rule, still applying to the blocks prior to the TIP-5 fork:
+// the hash must start with exactly miningDifficulty zero bytes.
+func IsBlockHashValid(hash Hash, miningDifficulty uint) bool {
+	if miningDifficulty >= uint(len(hash)) {
 		return false
 	}
 
-	return zeroesCount == miningDifficulty
+	for i := uint(0); i < miningDifficulty; i++ {
+		if hash[i] != 0 {
+			return false
+		}
+	}
+
+	return hash[miningDifficulty] != 0
 }
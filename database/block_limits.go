@@ -0,0 +1,142 @@
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BlockLimits caps the blocks a node accepts from the TIP-9 fork on, so a spammer can't make blocks,
+// and the sync responses carrying them, arbitrarily large. A zero limit is no limit.
+type BlockLimits struct {
+	MaxTXs  uint   // TXs in a block
+	MaxSize uint64 // bytes of the block TXs, JSON encoded
+}
+
+// NextBlockLimits returns the limits of the next block, none before the TIP-9 fork.
+func (s *State) NextBlockLimits() BlockLimits {
+	if !s.IsTIP9Fork() {
+		return BlockLimits{}
+	}
+
+	return s.blockLimits
+}
+
+// SelectTXs picks the highest-paying TXs fitting the limits, for miners to put in the next block.
+//
+// The TXs of a sender are picked in nonce order, a TX not fitting the limits leaves out the sender's next TXs
+// as they can't be applied without it. The picked TXs keep the mining order of OrderTXs.
+func (l BlockLimits) SelectTXs(txs []SignedTx) ([]SignedTx, error) {
+	ordered := OrderTXs(txs)
+	if l.MaxTXs == 0 && l.MaxSize == 0 {
+		return ordered, nil
+	}
+
+	senders := make([]common.Address, 0)
+	queues := make(map[common.Address][]SignedTx)
+	for _, tx := range ordered {
+		if _, ok := queues[tx.From]; !ok {
+			senders = append(senders, tx.From)
+		}
+
+		queues[tx.From] = append(queues[tx.From], tx)
+	}
+
+	selected := make(map[Hash]bool)
+	count := uint(0)
+	size := uint64(0)
+
+	for len(senders) > 0 && (l.MaxTXs == 0 || count < l.MaxTXs) {
+		// the earliest sender wins ties, keeping the time order among equally paying TXs
+		best := 0
+		for i, sender := range senders {
+			if queues[sender][0].GasCost().Cmp(queues[senders[best]][0].GasCost()) > 0 {
+				best = i
+			}
+		}
+
+		sender := senders[best]
+		tx := queues[sender][0]
+
+		txSize, err := encodedTxSize(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		if l.MaxSize != 0 && size+txSize > l.MaxSize {
+			senders = append(senders[:best], senders[best+1:]...)
+			continue
+		}
+
+		txHash, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		selected[txHash] = true
+		count++
+		size += txSize
+
+		queues[sender] = queues[sender][1:]
+		if len(queues[sender]) == 0 {
+			senders = append(senders[:best], senders[best+1:]...)
+		}
+	}
+
+	picked := make([]SignedTx, 0, count)
+	for _, tx := range ordered {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		if selected[txHash] {
+			picked = append(picked, tx)
+		}
+	}
+
+	return picked, nil
+}
+
+// validateLimits checks the block TXs fit the limits, the TIP-10 coinbase being left out.
+func (b Block) validateLimits(limits BlockLimits) error {
+	txs := make([]SignedTx, 0, len(b.TXs))
+	for _, tx := range b.TXs {
+		if !tx.IsCoinbase() {
+			txs = append(txs, tx)
+		}
+	}
+
+	if limits.MaxTXs != 0 && uint(len(txs)) > limits.MaxTXs {
+		return newBlockMismatchError(b.Header.Number, ErrBlockTooLarge, limits.MaxTXs, len(txs), "block '%d' has %d TXs, more than the limit of %d", b.Header.Number, len(txs), limits.MaxTXs)
+	}
+
+	if limits.MaxSize == 0 {
+		return nil
+	}
+
+	size := uint64(0)
+	for _, tx := range txs {
+		txSize, err := encodedTxSize(tx)
+		if err != nil {
+			return err
+		}
+
+		size += txSize
+	}
+
+	if size > limits.MaxSize {
+		return newBlockMismatchError(b.Header.Number, ErrBlockTooLarge, limits.MaxSize, size, "block '%d' TXs take %d bytes, more than the limit of %d", b.Header.Number, size, limits.MaxSize)
+	}
+
+	return nil
+}
+
+func encodedTxSize(tx SignedTx) (uint64, error) {
+	txJson, err := json.Marshal(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(len(txJson)), nil
+}
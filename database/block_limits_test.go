@@ -0,0 +1,96 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestBlockLimits_SelectTXs(t *testing.T) {
+	andrej := NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57")
+	babayaga := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+	caesar := NewAccount("0x09ee50f2f37fcba1845de6fe5c762e83e65e755c")
+
+	newTx := func(from common.Address, nonce uint, gasPrice uint, time uint64) SignedTx {
+		tx := NewTx(from, andrej, big.NewInt(1), nonce, amount(TxGas), amount(gasPrice), "")
+		tx.Time = time
+
+		return NewSignedTx(tx, []byte{})
+	}
+
+	andrejTx1 := newTx(andrej, 1, 1, 10)
+	andrejTx2 := newTx(andrej, 2, 9, 11)
+	babayagaTx1 := newTx(babayaga, 1, 5, 12)
+	caesarTx1 := newTx(caesar, 1, 3, 13)
+	txs := []SignedTx{caesarTx1, andrejTx2, babayagaTx1, andrejTx1}
+
+	txSize, err := encodedTxSize(andrejTx1)
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		limits BlockLimits
+		want   []SignedTx
+	}{
+		"unlimited": {
+			limits: BlockLimits{},
+			want:   []SignedTx{andrejTx1, andrejTx2, babayagaTx1, caesarTx1},
+		},
+		"highest paying first, keeping the mining order": {
+			limits: BlockLimits{MaxTXs: 2},
+			want:   []SignedTx{babayagaTx1, caesarTx1},
+		},
+		"a sender's TX waits for its lower nonce TX": {
+			limits: BlockLimits{MaxTXs: 4},
+			want:   []SignedTx{andrejTx1, andrejTx2, babayagaTx1, caesarTx1},
+		},
+		"fitting the size": {
+			limits: BlockLimits{MaxSize: 3*txSize + txSize/2},
+			want:   []SignedTx{andrejTx1, babayagaTx1, caesarTx1},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			selected, err := tc.limits.SelectTXs(txs)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, selected)
+		})
+	}
+}
+
+func TestState_BlockLimitsFork(t *testing.T) {
+	forkTIP9 := uint64(1)
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0, ForkTIP9: &forkTIP9, MaxBlockTXs: 2})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	txs := make([]SignedTx, 0)
+	for nonce := uint(1); nonce <= 5; nonce++ {
+		txs = append(txs, signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), nonce, ""), key))
+	}
+
+	// Prior to the fork, blocks can have any number of TXs
+	_, err := s.AddBlock(mineTestBlock(t, s, sender, txs[:3]))
+	require.NoError(t, err)
+
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, txs[3:]))
+	require.NoError(t, err)
+
+	more := []SignedTx{
+		signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 6, ""), key),
+		signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 7, ""), key),
+		signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 8, ""), key),
+	}
+
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, more))
+	assert.Error(t, err)
+
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, more[:2]))
+	require.NoError(t, err)
+}
@@ -31,4 +31,7 @@ func TestIsBlockHashValid(t *testing.T) {
 			assert.Equal(t, tc.wantValid, IsBlockHashValid(hash, 2)) // 4 leading zeros to be valid
 		})
 	}
+
+	// No hash is valid at the hardest difficulty, a zero hash has no non-zero byte past its leading zeros
+	assert.False(t, IsBlockHashValid(Hash{}, MaxMiningDifficulty))
 }
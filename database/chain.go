@@ -0,0 +1,325 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// sideChainMaxDepth is how many blocks below the main chain tip side chain blocks are kept for.
+// Reorganisations deeper than that aren't possible.
+const sideChainMaxDepth = 100
+
+// sideBlocksMax is how many side chain blocks are tracked at most.
+const sideBlocksMax = 10 * sideChainMaxDepth
+
+var (
+	ErrUnknownParent  = errors.New("unknown parent block")
+	ErrSideChainsFull = errors.New("too many side chain blocks")
+)
+
+// ChainUpdate describes how importing a block changed the main chain.
+type ChainUpdate struct {
+	Hash Hash
+	// Applied are the blocks which became part of the main chain, oldest first
+	Applied []Block
+	// Reverted are the blocks a reorganisation removed from the main chain, newest first
+	Reverted []Block
+	// Queued tells the block is ahead of the local clock and will be imported by ImportQueuedBlocks once its time comes
+	Queued bool
+}
+
+func (u ChainUpdate) IsReorg() bool {
+	return len(u.Reverted) > 0
+}
+
+// ImportBlock adds the block on top of the main chain if it extends it, or tracks it as part of a side chain otherwise.
+//
+// The fork choice rule picks the chain with the most cumulative proof-of-work: once a side chain has more work
+// than the main chain, the state is rolled back to their common ancestor and the side chain becomes the main chain.
+// Importing an already known block is a no-op, and blocks too far ahead of the local clock are queued until their time comes.
+func (s *State) ImportBlock(b Block) (ChainUpdate, error) {
+	hash, err := b.Hash()
+	if err != nil {
+		return ChainUpdate{}, err
+	}
+
+	if s.IsKnownBlock(hash) {
+		return ChainUpdate{Hash: hash}, nil
+	}
+
+	if s.isFromFuture(b) {
+		return s.queueFutureBlock(b, hash)
+	}
+
+	if !s.hasGenesisBlock || b.Header.Parent == s.latestBlockHash {
+		if err := s.extendMainChain(b, hash); err != nil {
+			return ChainUpdate{}, err
+		}
+
+		s.pruneSideBlocks()
+
+		return ChainUpdate{Hash: hash, Applied: []Block{b}}, nil
+	}
+
+	return s.importSideBlock(b, hash)
+}
+
+// IsKnownBlock tells whether the block is part of the main chain or of a tracked side chain.
+func (s *State) IsKnownBlock(hash Hash) bool {
+	if _, ok := s.sideBlocks[hash]; ok {
+		return true
+	}
+
+	_, err := s.db.GetByHash(hash)
+
+	return err == nil
+}
+
+// BlockLocator returns main chain block hashes to find the latest block shared with a peer.
+//
+// The hashes go from the tip backwards in exponentially growing steps and end with an empty hash,
+// standing for the chain start, so the common ancestor of two chains can be found in a few requests.
+func (s *State) BlockLocator() []Hash {
+	locator := make([]Hash, 0)
+
+	if s.hasGenesisBlock {
+		step := uint64(1)
+		for height := s.latestBlock.Header.Number; ; height -= step {
+			if blockFs, err := s.db.GetByHeight(height); err == nil {
+				locator = append(locator, blockFs.Key)
+			}
+
+			if len(locator) > 2 {
+				step *= 2
+			}
+
+			if height < step {
+				break
+			}
+		}
+	}
+
+	return append(locator, Hash{})
+}
+
+func (s *State) importSideBlock(b Block, hash Hash) (ChainUpdate, error) {
+	if b.Header.Parent.IsEmpty() {
+		if b.Header.Number != 0 {
+			return ChainUpdate{}, newBlockMismatchError(b.Header.Number, ErrBadBlockNumber, uint64(0), b.Header.Number, "block '%x' without parent must be number '0' not '%d'", hash, b.Header.Number)
+		}
+	} else {
+		parent, ok := s.getMainOrSideBlock(b.Header.Parent)
+		if !ok {
+			return ChainUpdate{}, fmt.Errorf("%w '%x' of block '%x'", ErrUnknownParent, b.Header.Parent, hash)
+		}
+
+		if b.Header.Number != parent.Header.Number+1 {
+			return ChainUpdate{}, newBlockMismatchError(b.Header.Number, ErrBadBlockNumber, parent.Header.Number+1, b.Header.Number, "block '%x' number must be '%d' not '%d'", hash, parent.Header.Number+1, b.Header.Number)
+		}
+	}
+
+	// The block's TXs can only be validated against the state of its own branch, on reorganisation
+	if !s.isBlockHashValid(hash, b) {
+		return ChainUpdate{}, newBlockError(b.Header.Number, ErrBadPoW, "invalid block hash %x", hash)
+	}
+
+	branch, err := s.sideBranchOf(BlockFS{Key: hash, Value: b})
+	if err != nil {
+		return ChainUpdate{}, err
+	}
+
+	forkHeight := branch[0].Value.Header.Number
+
+	// The block hash is only checked against the target the block claims, which must be one its branch can have
+	easiestTarget, err := s.easiestSideBlockTarget(forkHeight, b.Header.Number)
+	if err != nil {
+		return ChainUpdate{}, err
+	}
+
+	if s.targetOf(b).Cmp(easiestTarget) > 0 {
+		return ChainUpdate{}, newBlockError(b.Header.Number, ErrBadDifficulty, "side chain block '%x' target is easier than the chain forked at height %d can have", hash, forkHeight)
+	}
+
+	if len(s.sideBlocks) >= sideBlocksMax {
+		return ChainUpdate{}, fmt.Errorf("%w. can't track block '%x', %d side chain blocks are already tracked", ErrSideChainsFull, hash, len(s.sideBlocks))
+	}
+
+	s.sideBlocks[hash] = b
+
+	branchWork := big.NewInt(0)
+	for _, blockFs := range branch {
+		branchWork.Add(branchWork, s.workOf(blockFs.Value))
+	}
+
+	mainWork, err := s.mainChainWorkFrom(forkHeight)
+	if err != nil {
+		return ChainUpdate{}, err
+	}
+
+	if branchWork.Cmp(mainWork) <= 0 {
+		fmt.Printf("block '%x' extends a side chain forked at height %d\n", hash, forkHeight)
+
+		return ChainUpdate{Hash: hash}, nil
+	}
+
+	return s.reorganise(forkHeight, branch)
+}
+
+// sideBranchOf returns the side chain blocks leading to the given one, oldest first.
+// The first block's parent is on the main chain.
+func (s *State) sideBranchOf(tip BlockFS) ([]BlockFS, error) {
+	branch := []BlockFS{tip}
+
+	for {
+		parentHash := branch[0].Value.Header.Parent
+		parent, ok := s.sideBlocks[parentHash]
+		if !ok {
+			break
+		}
+
+		branch = append([]BlockFS{{Key: parentHash, Value: parent}}, branch...)
+	}
+
+	first := branch[0].Value
+	if !first.Header.Parent.IsEmpty() {
+		if _, err := s.db.GetByHash(first.Header.Parent); err != nil {
+			return nil, fmt.Errorf("side chain of block '%x' doesn't fork from the main chain. %w", tip.Key, ErrUnknownParent)
+		}
+	}
+
+	return branch, nil
+}
+
+// easiestSideBlockTarget returns the easiest target a block at the height can have on a side chain forked at the height,
+// starting from the target of the main chain block the side chain forks from.
+func (s *State) easiestSideBlockTarget(forkHeight uint64, number uint64) (*big.Int, error) {
+	if forkHeight == 0 {
+		return s.easiestTargetAt(s.genesisTarget(), 0, number), nil
+	}
+
+	forkParent, err := s.db.GetByHeight(forkHeight - 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.easiestTargetAt(s.targetOf(forkParent.Value), forkHeight-1, number), nil
+}
+
+// mainChainWorkFrom sums the proof-of-work of the main chain blocks from the given height up.
+func (s *State) mainChainWorkFrom(height uint64) (*big.Int, error) {
+	work := big.NewInt(0)
+
+	err := s.db.Iterate(height, func(blockFs BlockFS) error {
+		work.Add(work, s.workOf(blockFs.Value))
+
+		return nil
+	})
+
+	return work, err
+}
+
+// reorganise replaces the main chain blocks from the fork height up with the side chain branch.
+//
+// The branch blocks are fully validated against the state of their common ancestor first.
+// If any of them is invalid, the main chain is left untouched and the invalid blocks are forgotten.
+// The stored blocks are swapped through a journal, see swapMainChain.
+func (s *State) reorganise(forkHeight uint64, branch []BlockFS) (ChainUpdate, error) {
+	tip := branch[len(branch)-1]
+	fmt.Printf("\nside chain of block '%x' has more work, reorganising the chain from height %d\n", tip.Key, forkHeight)
+
+	reverted := make([]BlockFS, 0)
+	err := s.db.Iterate(forkHeight, func(blockFs BlockFS) error {
+		reverted = append([]BlockFS{blockFs}, reverted...)
+
+		return nil
+	})
+	if err != nil {
+		return ChainUpdate{}, err
+	}
+
+	pendingState := s.copy()
+	if err := pendingState.replayMainChain(forkHeight, 0); err != nil {
+		return ChainUpdate{}, err
+	}
+
+	for i, blockFs := range branch {
+		receipts, err := applyBlock(blockFs.Value, &pendingState)
+		if err != nil {
+			for _, invalid := range branch[i:] {
+				delete(s.sideBlocks, invalid.Key)
+			}
+
+			return ChainUpdate{}, fmt.Errorf("side chain block '%x' is invalid. %w", blockFs.Key, err)
+		}
+
+		pendingState.setLatestBlock(blockFs.Key, blockFs.Value)
+		branch[i].Receipts = receipts
+	}
+
+	// The reverted blocks are newest first, restored oldest first if the branch can't be stored
+	replaced := make([]BlockFS, 0, len(reverted))
+	for i := len(reverted) - 1; i >= 0; i-- {
+		replaced = append(replaced, reverted[i])
+	}
+
+	if err := swapMainChain(s.dataDir, s.db, forkHeight, branch, replaced); err != nil {
+		// forgotten so importing the block again retries the reorganisation
+		delete(s.sideBlocks, tip.Key)
+
+		return ChainUpdate{}, err
+	}
+
+	// The branch is stored, from here on the state follows it whatever happens
+	update := ChainUpdate{Hash: tip.Key}
+
+	for _, blockFs := range branch {
+		delete(s.sideBlocks, blockFs.Key)
+		update.Applied = append(update.Applied, blockFs.Value)
+	}
+
+	for _, blockFs := range reverted {
+		s.sideBlocks[blockFs.Key] = blockFs.Value
+		update.Reverted = append(update.Reverted, blockFs.Value)
+	}
+
+	s.indexMainChain(forkHeight, branch)
+	s.commit(pendingState)
+	s.snapshotIfDue()
+	s.pruneSideBlocks()
+
+	fmt.Printf("chain reorganised: %d blocks reverted, %d blocks applied. new tip '%x'\n", len(reverted), len(branch), tip.Key)
+
+	return update, nil
+}
+
+// workOf returns the proof-of-work the block represents.
+func (s *State) workOf(b Block) *big.Int {
+	if b.Header.Bits != 0 {
+		return TargetWork(b.Header.Bits)
+	}
+
+	return BlockWork(s.difficultyOf(b))
+}
+
+func (s *State) getMainOrSideBlock(hash Hash) (Block, bool) {
+	if b, ok := s.sideBlocks[hash]; ok {
+		return b, true
+	}
+
+	blockFs, err := s.db.GetByHash(hash)
+	if err != nil {
+		return Block{}, false
+	}
+
+	return blockFs.Value, true
+}
+
+// pruneSideBlocks forgets side chain blocks too far below the main chain tip to ever win the fork choice.
+func (s *State) pruneSideBlocks() {
+	for hash, b := range s.sideBlocks {
+		if b.Header.Number+sideChainMaxDepth < s.latestBlock.Header.Number {
+			delete(s.sideBlocks, hash)
+		}
+	}
+}
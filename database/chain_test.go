@@ -0,0 +1,376 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+	"time"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestState_ImportBlockReorganisesToMostWork(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+	otherReceiver := NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57")
+
+	block0 := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)})
+	block0Hash, err := s.AddBlock(block0)
+	require.NoError(t, err)
+
+	block1 := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(20), 2, ""), key)})
+	block1Hash, err := s.AddBlock(block1)
+	require.NoError(t, err)
+
+	// A competing block at the same height has the same work, the first seen block stays in the main chain
+	sideBlock1 := mineTestBlockOn(t, s, block0Hash, 1, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, otherReceiver, big.NewInt(30), 2, ""), key)})
+	update, err := s.ImportBlock(sideBlock1)
+	require.NoError(t, err)
+	assert.False(t, update.IsReorg())
+	assert.Empty(t, update.Applied)
+	assert.Equal(t, block1Hash, s.LatestBlockHash())
+	assert.True(t, s.IsKnownBlock(update.Hash))
+
+	// Extending the side chain gives it more work than the main chain
+	sideBlock2 := mineTestBlockOn(t, s, update.Hash, 2, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, otherReceiver, big.NewInt(40), 3, ""), key)})
+	update, err = s.ImportBlock(sideBlock2)
+	require.NoError(t, err)
+	assert.True(t, update.IsReorg())
+	assert.Equal(t, []Block{block1}, update.Reverted)
+	assert.Equal(t, []Block{sideBlock1, sideBlock2}, update.Applied)
+
+	assert.Equal(t, update.Hash, s.LatestBlockHash())
+	assert.Equal(t, big.NewInt(10).String(), s.Balance(receiver).String())
+	assert.Equal(t, big.NewInt(70).String(), s.Balance(otherReceiver).String())
+	assert.Equal(t, uint(3), s.AccountToNonce[sender])
+	assert.True(t, s.IsKnownBlock(block1Hash))
+
+	mainBlock1, err := s.GetBlockByHeight(1)
+	require.NoError(t, err)
+	assert.Equal(t, sideBlock1, mainBlock1)
+
+	// The reorganised chain is the one persisted
+	expectedBalances := s.Balances
+	require.NoError(t, s.Close())
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	assert.Equal(t, update.Hash, restarted.LatestBlockHash())
+	assertBalances(t, expectedBalances, restarted.Balances)
+}
+
+func TestState_ImportBlockRejectsInvalidSideChain(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	block0Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)}))
+	require.NoError(t, err)
+
+	block1Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(20), 2, ""), key)}))
+	require.NoError(t, err)
+
+	expectedBalances := s.copy().Balances
+
+	// The side chain replays the nonce 1 TX already spent in block 0
+	replayedTx := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)
+	sideBlock1 := mineTestBlockOn(t, s, block0Hash, 1, sender, []SignedTx{replayedTx})
+	update, err := s.ImportBlock(sideBlock1)
+	require.NoError(t, err)
+
+	_, err = s.ImportBlock(mineTestBlockOn(t, s, update.Hash, 2, sender, []SignedTx{}))
+	assert.Error(t, err)
+
+	assert.Equal(t, block1Hash, s.LatestBlockHash())
+	assertBalances(t, expectedBalances, s.Balances)
+	assert.False(t, s.IsKnownBlock(update.Hash))
+
+	// Blocks of unknown parents can't be tracked
+	_, err = s.ImportBlock(mineTestBlockOn(t, s, Hash{1}, 5, sender, []SignedTx{}))
+	assert.True(t, errors.Is(err, ErrUnknownParent))
+}
+
+func TestState_ImportBlockRejectsCheapSideBlocks(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	block0Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)}))
+	require.NoError(t, err)
+
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{}))
+	require.NoError(t, err)
+
+	// A target easier than the chain's can't be claimed to fill the side chains with forks for free
+	easyBlock := NewBlock(block0Hash, 1, 0, uint64(time.Now().Unix()), sender, []SignedTx{})
+	easyBlock.Header.Bits = 0x2100ffff
+	_, err = s.ImportBlock(mineTestBlockWithHeader(t, s, easyBlock))
+	assert.True(t, errors.Is(err, ErrBadDifficulty))
+	assert.Empty(t, s.sideBlocks)
+
+	easyBlock.Header.Time = uint64(time.Now().Unix()) + s.maxBlockTimeDrift + 60
+	_, err = s.ImportBlock(mineTestBlockWithHeader(t, s, easyBlock))
+	assert.True(t, errors.Is(err, ErrBadDifficulty))
+	assert.Empty(t, s.futureBlocks)
+
+	// The tracked side chain blocks are capped
+	for i := 0; i < sideBlocksMax; i++ {
+		s.sideBlocks[Hash{byte(i), byte(i >> 8), 1}] = Block{}
+	}
+
+	_, err = s.ImportBlock(mineTestBlockOn(t, s, block0Hash, 1, receiver, []SignedTx{}))
+	assert.True(t, errors.Is(err, ErrSideChainsFull))
+	assert.Len(t, s.sideBlocks, sideBlocksMax)
+}
+
+// failingAppendStore fails appending the blocks matching failAppend, standing for a full disk or an IO error.
+type failingAppendStore struct {
+	BlockStore
+	failAppend func(blockFs BlockFS) bool
+}
+
+func (fs *failingAppendStore) Append(blockFs BlockFS) error {
+	if fs.failAppend(blockFs) {
+		return errors.New("injected append failure")
+	}
+
+	return fs.BlockStore.Append(blockFs)
+}
+
+func TestState_ReorganisationSurvivesAppendFailure(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+	otherReceiver := NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57")
+
+	block0Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)}))
+	require.NoError(t, err)
+
+	block1 := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(20), 2, ""), key)})
+	block1Hash, err := s.AddBlock(block1)
+	require.NoError(t, err)
+
+	sideBlock1 := mineTestBlockOn(t, s, block0Hash, 1, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, otherReceiver, big.NewInt(30), 2, ""), key)})
+	update, err := s.ImportBlock(sideBlock1)
+	require.NoError(t, err)
+
+	sideBlock2 := mineTestBlockOn(t, s, update.Hash, 2, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, otherReceiver, big.NewInt(40), 3, ""), key)})
+	sideBlock2Hash, err := sideBlock2.Hash()
+	require.NoError(t, err)
+
+	expectedBalances := s.copy().Balances
+	store := &failingAppendStore{BlockStore: s.db}
+	s.db = store
+
+	// Storing the side chain fails half way, the main chain blocks are restored
+	store.failAppend = func(blockFs BlockFS) bool { return blockFs.Key == sideBlock2Hash }
+	_, err = s.ImportBlock(sideBlock2)
+	require.Error(t, err)
+
+	assert.Equal(t, block1Hash, s.LatestBlockHash())
+	assertBalances(t, expectedBalances, s.Balances)
+	assert.False(t, utils.FileExist(getReorgJournalFilePath(s.dataDir)))
+
+	mainBlock1, err := s.GetBlockByHeight(1)
+	require.NoError(t, err)
+	assert.Equal(t, block1, mainBlock1)
+
+	// Restoring the main chain fails too, as if the node crashed, the reorganisation is finished on the next start
+	store.failAppend = func(blockFs BlockFS) bool { return blockFs.Key != update.Hash }
+	_, err = s.ImportBlock(sideBlock2)
+	require.Error(t, err)
+	assert.True(t, utils.FileExist(getReorgJournalFilePath(s.dataDir)))
+	require.NoError(t, s.Close())
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	assert.Equal(t, sideBlock2Hash, restarted.LatestBlockHash())
+	assert.Equal(t, big.NewInt(10).String(), restarted.Balance(receiver).String())
+	assert.Equal(t, big.NewInt(70).String(), restarted.Balance(otherReceiver).String())
+	assert.False(t, utils.FileExist(getReorgJournalFilePath(s.dataDir)))
+}
+
+func TestState_TxRootFork(t *testing.T) {
+	forkTIP2 := uint64(1)
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0, ForkTIP2: &forkTIP2})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	legacyTx := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)
+	legacyBlock := mineTestBlock(t, s, sender, []SignedTx{legacyTx})
+	assert.Nil(t, legacyBlock.Header.TxRoot)
+
+	_, err := s.AddBlock(legacyBlock)
+	require.NoError(t, err)
+
+	txs := []SignedTx{
+		signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(20), 2, ""), key),
+		signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(30), 3, ""), key),
+		signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(40), 4, ""), key),
+	}
+
+	// The block header must commit to its TXs from the fork on
+	withoutRoot := mineTestBlock(t, s, sender, txs)
+	withoutRoot.Header.TxRoot = nil
+	_, err = s.AddBlock(withoutRoot)
+	assert.Error(t, err)
+
+	// Swapping TXs doesn't change the block hash, but doesn't match the TX root
+	block := mineTestBlock(t, s, sender, txs)
+	swapped := block
+	swapped.TXs = txs[:2]
+	_, err = s.AddBlock(swapped)
+	assert.Error(t, err)
+
+	blockHash, err := s.AddBlock(block)
+	require.NoError(t, err)
+
+	txHash, err := txs[1].Hash()
+	require.NoError(t, err)
+
+	blockFs, proof, err := s.GetTxProof(txHash)
+	require.NoError(t, err)
+	assert.Equal(t, blockHash, blockFs.Key)
+	assert.True(t, VerifyTxProof(proof, *blockFs.Value.Header.TxRoot))
+
+	// The header alone is enough to check the block hash
+	headerOnly := Block{Header: blockFs.Value.Header}
+	headerHash, err := headerOnly.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, blockHash, headerHash)
+
+	legacyTxHash, err := legacyTx.Hash()
+	require.NoError(t, err)
+	_, _, err = s.GetTxProof(legacyTxHash)
+	assert.Error(t, err)
+
+	_, _, err = s.GetTxProof(Hash{1})
+	assert.True(t, errors.Is(err, ErrTxNotFound))
+}
+
+func TestState_StateRootFork(t *testing.T) {
+	forkTIP3 := uint64(1)
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0, ForkTIP3: &forkTIP3})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	// Prior to the fork, blocks can't commit to a state root
+	legacyBlock := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)})
+	assert.Nil(t, legacyBlock.Header.StateRoot)
+
+	stateRoot := s.StateRoot()
+	legacyBlock.Header.StateRoot = &stateRoot
+	_, err := s.AddBlock(mineTestBlockWithHeader(t, s, legacyBlock))
+	assert.Error(t, err)
+
+	legacyBlock.Header.StateRoot = nil
+	_, err = s.AddBlock(legacyBlock)
+	require.NoError(t, err)
+
+	txs := []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(20), 2, ""), key)}
+
+	block := mineTestBlock(t, s, sender, txs)
+	require.NotNil(t, block.Header.StateRoot)
+
+	withoutRoot := block
+	withoutRoot.Header.StateRoot = nil
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, withoutRoot))
+	assert.Error(t, err)
+
+	// A node computing another state than the miner rejects the block
+	divergentRoot := *block.Header.StateRoot
+	divergentRoot[0]++
+	divergent := block
+	divergent.Header.StateRoot = &divergentRoot
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, divergent))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "state root")
+
+	_, err = s.AddBlock(block)
+	require.NoError(t, err)
+	assert.Equal(t, *block.Header.StateRoot, s.StateRoot())
+
+	// The state root is deterministic, a node replaying the chain reaches the same one
+	expectedRoot := s.StateRoot()
+	require.NoError(t, s.Close())
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	defer restarted.Close()
+	assert.Equal(t, expectedRoot, restarted.StateRoot())
+}
+
+func TestState_StateRootIgnoresEmptyAccounts(t *testing.T) {
+	s, _, _ := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	stateRoot := s.StateRoot()
+
+	s.Balances[NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")] = big.NewInt(0)
+	assert.Equal(t, stateRoot, s.StateRoot())
+
+	s.Balances[NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")] = big.NewInt(1)
+	assert.NotEqual(t, stateRoot, s.StateRoot())
+}
+
+func TestState_ChainIDFork(t *testing.T) {
+	forkTIP8 := uint64(1)
+	s, key, sender := newTestState(t, Genesis{ChainID: "tbb-test", ForkTIP1: 0, ForkTIP8: &forkTIP8})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	// Prior to the fork, TXs can't have a chain ID and encode as before
+	tx := NewBaseTx(sender, receiver, big.NewInt(10), 1, "")
+	txJson, err := tx.Encode()
+	require.NoError(t, err)
+	assert.NotContains(t, string(txJson), "chain_id")
+
+	tx.ChainID = "tbb-test"
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, tx, key)}))
+	assert.Error(t, err)
+
+	tx.ChainID = ""
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, tx, key)}))
+	require.NoError(t, err)
+
+	// From the fork on, TXs must be signed for this network
+	tx = NewBaseTx(sender, receiver, big.NewInt(20), 2, "")
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, tx, key)}))
+	assert.Error(t, err)
+
+	tx.ChainID = "tbb-other"
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, tx, key)}))
+	assert.Error(t, err)
+
+	// A TX signed for another network can't be replayed by changing its chain ID
+	signedTx := signTestTx(t, tx, key)
+	signedTx.ChainID = "tbb-test"
+	isAuthentic, err := signedTx.IsAuthentic()
+	require.NoError(t, err)
+	assert.False(t, isAuthentic)
+
+	tx.ChainID = "tbb-test"
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, tx, key)}))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(30).String(), s.Balance(receiver).String())
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// From the TIP-10 fork on, the block reward and the TX fees are paid to the miner by a coinbase TX,
+// the first TX of every block, instead of being credited implicitly. So the TX history shows where new coins come from.
+//
+// The coinbase is sent from the zero address, it isn't signed nor pays any gas. Its nonce is the block number,
+// so the coinbases paying the same miner the same value in different blocks still have different hashes.
+
+// CoinbaseTxData is the data of coinbase TXs, telling them apart from the TXs sent by accounts.
+const CoinbaseTxData = "reward"
+
+// IsCoinbase tells whether the TX pays the block reward and fees to the miner, from the TIP-10 fork on.
+func (t Tx) IsCoinbase() bool {
+	return t.From == common.Address{} && t.IsReward()
+}
+
+// NewCoinbaseTx returns the coinbase paying the miner of the next block the reward and the fees of the given TXs.
+func (s *State) NewCoinbaseTx(miner common.Address, txs []SignedTx) (SignedTx, error) {
+	value := new(big.Int).Add(s.NextBlockReward(), blockFees(txs, s.IsTIP1Fork()))
+
+	tx := Tx{
+		From:  common.Address{},
+		To:    miner,
+		Value: value,
+		Nonce: uint(s.NextBlockNumber()),
+		Data:  CoinbaseTxData,
+		Time:  uint64(s.clock().Unix()),
+	}
+
+	return NewSignedTx(tx, []byte{}), nil
+}
+
+// validateCoinbase checks the block starts with a coinbase paying its miner exactly the reward and the fees of the other TXs.
+func (b Block) validateCoinbase(reward *big.Int, isTIP1Fork bool) error {
+	if len(b.TXs) == 0 || !b.TXs[0].IsCoinbase() {
+		return newBlockError(b.Header.Number, ErrBadCoinbase, "block '%d' must start with a coinbase TX from the TIP-10 fork on", b.Header.Number)
+	}
+
+	for i, tx := range b.TXs[1:] {
+		if tx.IsCoinbase() {
+			return newBlockError(b.Header.Number, ErrBadCoinbase, "block '%d' TX no. %d is a second coinbase", b.Header.Number, i+1)
+		}
+	}
+
+	coinbase := b.TXs[0]
+	if coinbase.To != b.Header.Miner {
+		return newBlockMismatchError(b.Header.Number, ErrBadCoinbase, b.Header.Miner, coinbase.To, "block '%d' coinbase must pay the miner '%s' not '%s'", b.Header.Number, b.Header.Miner.String(), coinbase.To.String())
+	}
+
+	if coinbase.Nonce != uint(b.Header.Number) {
+		return newBlockMismatchError(b.Header.Number, ErrBadCoinbase, uint(b.Header.Number), coinbase.Nonce, "block '%d' coinbase nonce must be the block number, not '%d'", b.Header.Number, coinbase.Nonce)
+	}
+
+	if amountOrZero(coinbase.Gas).Sign() != 0 || amountOrZero(coinbase.GasPrice).Sign() != 0 || coinbase.ChainID != "" || len(coinbase.Sig) != 0 || coinbase.IsMultisig() {
+		return newBlockError(b.Header.Number, ErrBadCoinbase, "block '%d' coinbase can't have gas, a chain ID nor a signature", b.Header.Number)
+	}
+
+	value := new(big.Int).Add(reward, blockFees(b.TXs[1:], isTIP1Fork))
+	if amountOrZero(coinbase.Value).Cmp(value) != 0 {
+		return newBlockMismatchError(b.Header.Number, ErrBadCoinbase, value, amountOrZero(coinbase.Value), "block '%d' coinbase must pay %s TBB not %s TBB", b.Header.Number, value, amountOrZero(coinbase.Value))
+	}
+
+	return nil
+}
+
+// applyCoinbase credits the miner with the coinbase value, the coinbase having been validated with the block.
+func applyCoinbase(tx SignedTx, s *State) (Receipt, error) {
+	s.credit(tx.To, amountOrZero(tx.Value))
+
+	txHash, err := tx.Hash()
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	return Receipt{
+		TxHash:      txHash,
+		Status:      ReceiptStatusApplied,
+		IsTIP1Fork:  s.IsTIP1Fork(),
+		Cost:        big.NewInt(0),
+		GasUsed:     big.NewInt(0),
+		GasPrice:    big.NewInt(0),
+		Fee:         big.NewInt(0),
+		FromBalance: s.Balance(tx.From),
+		ToBalance:   s.Balance(tx.To),
+	}, nil
+}
+
+// blockFees sums up the fees the TXs pay to the block miner, the fixed TxFee per TX prior to TIP-1.
+func blockFees(txs []SignedTx, isTIP1Fork bool) *big.Int {
+	if !isTIP1Fork {
+		return new(big.Int).Mul(amount(uint(len(txs))), amount(TxFee))
+	}
+
+	fees := big.NewInt(0)
+	for _, tx := range txs {
+		fees.Add(fees, tx.GasCost())
+	}
+
+	return fees
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestState_CoinbaseFork(t *testing.T) {
+	forkTIP10 := uint64(1)
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0, ForkTIP10: &forkTIP10})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	miner := NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57")
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	// Prior to the fork, the reward and fees are credited without a TX
+	tx1 := signTestTx(t, NewTx(sender, receiver, big.NewInt(10), 1, amount(TxGas), big.NewInt(2), ""), key)
+	_, err := s.AddBlock(mineTestBlock(t, s, miner, []SignedTx{tx1}))
+	require.NoError(t, err)
+	assert.Equal(t, "142", s.Balance(miner).String())
+
+	// From the fork on, a block must start with a coinbase paying exactly the reward and fees
+	tx2 := signTestTx(t, NewTx(sender, receiver, big.NewInt(10), 2, amount(TxGas), big.NewInt(3), ""), key)
+
+	block := NewBlock(s.LatestBlockHash(), s.NextBlockNumber(), 0, 0, miner, []SignedTx{tx2})
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, block))
+	assert.True(t, errors.Is(err, ErrBadCoinbase))
+
+	coinbase, err := s.NewCoinbaseTx(miner, []SignedTx{tx2})
+	require.NoError(t, err)
+	assert.True(t, coinbase.IsCoinbase())
+	assert.Equal(t, amount(BlockReward+TxGas*3).String(), coinbase.Value.String())
+
+	overpaying := coinbase
+	overpaying.Value = new(big.Int).Add(coinbase.Value, big.NewInt(1))
+	block = NewBlock(s.LatestBlockHash(), s.NextBlockNumber(), 0, 0, miner, []SignedTx{overpaying, tx2})
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, block))
+	assert.True(t, errors.Is(err, ErrBadCoinbase))
+
+	var blockErr *BlockError
+	require.True(t, errors.As(err, &blockErr))
+	assert.Equal(t, coinbase.Value.String(), blockErr.Expected.(*big.Int).String())
+	assert.Equal(t, overpaying.Value.String(), blockErr.Actual.(*big.Int).String())
+
+	otherMiner := coinbase
+	otherMiner.To = receiver
+	block = NewBlock(s.LatestBlockHash(), s.NextBlockNumber(), 0, 0, miner, []SignedTx{otherMiner, tx2})
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, block))
+	assert.True(t, errors.Is(err, ErrBadCoinbase))
+
+	blockHash, err := s.AddBlock(mineTestBlock(t, s, miner, []SignedTx{tx2}))
+	require.NoError(t, err)
+	assert.Equal(t, "305", s.Balance(miner).String())
+
+	// The coinbase is indexed and has a receipt like any other TX
+	mined, err := s.GetBlockByHash(blockHash)
+	require.NoError(t, err)
+	require.Len(t, mined.TXs, 2)
+	require.True(t, mined.TXs[0].IsCoinbase())
+
+	coinbaseHash, err := mined.TXs[0].Hash()
+	require.NoError(t, err)
+
+	_, location, err := s.GetTx(coinbaseHash)
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), location.Index)
+
+	receipt, err := s.GetReceipt(coinbaseHash)
+	require.NoError(t, err)
+	assert.Equal(t, "0", receipt.Cost.String())
+	assert.Equal(t, "305", receipt.ToBalance.String())
+
+	locations, _ := s.GetAccountTXs(miner, 0, 0)
+	assert.Equal(t, []TxLocation{location}, locations)
+}
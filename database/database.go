@@ -1,48 +1,83 @@
 package database
 
 import (
-	"bufio"
-	"encoding/json"
-	"os"
+	"errors"
+	"fmt"
 	"reflect"
 )
 
-func GetBlocksAfter(blockHash Hash, dataDir string) ([]Block, error) {
-	f, err := os.OpenFile(getBlocksDbFilePath(dataDir), os.O_RDONLY, 0600)
-	if err != nil {
-		return nil, err
-	}
-
-	blocks := make([]Block, 0)
-	shouldStartCollecting := false
+var (
+	ErrBlockNotFound = errors.New("block not found")
+	ErrTxNotFound    = errors.New("transaction not found")
+	// ErrHistoryUnavailable is a past state too far from the nearest snapshot to be rebuilt
+	ErrHistoryUnavailable = errors.New("state history unavailable")
+)
 
-	if reflect.DeepEqual(blockHash, Hash{}) {
-		shouldStartCollecting = true // from first block
-	}
+// GetBlocksAfter returns all the blocks persisted after the given block hash, seeking straight to it
+// through the block store indexes instead of decoding the database from the start.
+func (s *State) GetBlocksAfter(blockHash Hash) ([]Block, error) {
+	fromHeight := uint64(0) // from first block
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return nil, err
+	if !reflect.DeepEqual(blockHash, Hash{}) {
+		blockFs, err := s.db.GetByHash(blockHash)
+		if err == ErrBlockNotFound {
+			return []Block{}, nil
 		}
 
-		// each line represents a block
-		var blockFs BlockFS
-		if err := json.Unmarshal(scanner.Bytes(), &blockFs); err != nil {
+		if err != nil {
 			return nil, err
 		}
 
-		if shouldStartCollecting {
-			blocks = append(blocks, blockFs.Value)
+		// start collecting blocks from the next block on
+		fromHeight = blockFs.Value.Header.Number + 1
+	}
 
-			continue
-		}
+	blocks := make([]Block, 0)
+	err := s.db.Iterate(fromHeight, func(blockFs BlockFS) error {
+		blocks = append(blocks, blockFs.Value)
 
-		// start collecting blocks from the next block on
-		if blockFs.Key == blockHash {
-			shouldStartCollecting = true
-		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return blocks, nil
 }
+
+func (s *State) GetBlockByHash(blockHash Hash) (Block, error) {
+	blockFs, err := s.db.GetByHash(blockHash)
+
+	return blockFs.Value, err
+}
+
+func (s *State) GetBlockByHeight(height uint64) (Block, error) {
+	blockFs, err := s.db.GetByHeight(height)
+
+	return blockFs.Value, err
+}
+
+// GetTxProof finds the main chain block including the TX and proves the inclusion against the block TX root.
+// Only blocks mined from the TIP-2 fork on commit to their TXs in the header.
+func (s *State) GetTxProof(txHash Hash) (BlockFS, TxProof, error) {
+	location, ok := s.txIndex.byHash[txHash]
+	if !ok {
+		return BlockFS{}, TxProof{}, fmt.Errorf("%w '%x'", ErrTxNotFound, txHash)
+	}
+
+	found, err := s.db.GetByHash(location.BlockHash)
+	if err != nil {
+		return BlockFS{}, TxProof{}, err
+	}
+
+	if found.Value.Header.TxRoot == nil {
+		return BlockFS{}, TxProof{}, fmt.Errorf("block '%x' including TX '%x' predates the TIP-2 fork and has no TX root", found.Key, txHash)
+	}
+
+	proof, err := NewTxProof(found.Value.TXs, txHash)
+	if err != nil {
+		return BlockFS{}, TxProof{}, err
+	}
+
+	return found, proof, nil
+}
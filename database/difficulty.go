@@ -0,0 +1,160 @@
+package database
+
+import (
+	"math/big"
+)
+
+// A retarget only changes the difficulty when the block time is off by more than this factor.
+// Every difficulty step makes mining 256 times harder, so the step is only worth it past the midpoint, sqrt(256).
+const retargetThreshold = 16
+
+// MaxMiningDifficulty is the hardest difficulty, every byte of the 32 bytes hash being zero.
+const MaxMiningDifficulty = 32
+
+// NextBlockDifficulty returns the mining difficulty of the next block.
+//
+// Prior to the TIP-4 fork the difficulty is a node setting. From the fork on it's a consensus rule recorded in the
+// block headers: it starts at the genesis difficulty and is recomputed every retarget interval from the time
+// the previous interval took, toward the genesis target block time.
+func (s *State) NextBlockDifficulty() uint {
+	if !s.IsTIP4Fork() {
+		return s.miningDifficulty
+	}
+
+	number := s.NextBlockNumber()
+	if number == s.forkTIP4 {
+		return s.tip4Difficulty
+	}
+
+	parentDifficulty := s.latestBlock.Header.Difficulty
+	if (number-s.forkTIP4)%s.retargetInterval != 0 {
+		return parentDifficulty
+	}
+
+	return retargetDifficulty(parentDifficulty, s.retargetElapsedTime(), s.retargetExpectedTime())
+}
+
+// NextBlockBits returns the compact target the next block hash must be lower than, from the TIP-5 fork on.
+//
+// With the difficulty retargeting of TIP-4 active, the target starts from the one equivalent to the last difficulty
+// and is retargeted every interval, by finer steps than the difficulty was.
+// Otherwise it's the target equivalent to the node difficulty.
+func (s *State) NextBlockBits() uint32 {
+	if !s.IsTIP4Fork() {
+		return DifficultyToBits(s.miningDifficulty)
+	}
+
+	parentBits := s.latestBlock.Header.Bits
+	if parentBits == 0 {
+		return DifficultyToBits(s.NextBlockDifficulty())
+	}
+
+	if (s.NextBlockNumber()-s.forkTIP4)%s.retargetInterval != 0 {
+		return parentBits
+	}
+
+	return retargetBits(parentBits, s.retargetElapsedTime(), s.retargetExpectedTime())
+}
+
+// retargetElapsedTime is the time between the first and the last block of the retarget interval.
+func (s *State) retargetElapsedTime() uint64 {
+	if s.latestBlock.Header.Time > s.retargetStartTime {
+		return s.latestBlock.Header.Time - s.retargetStartTime
+	}
+
+	return 0
+}
+
+// retargetExpectedTime is the time a retarget interval should take.
+// The interval's first block is its start, the time elapsed spans the gaps to its last block.
+func (s *State) retargetExpectedTime() uint64 {
+	return (s.retargetInterval - 1) * s.targetBlockTime
+}
+
+// retargetDifficulty adjusts the difficulty by one step when the blocks were mined way faster or slower than expected.
+func retargetDifficulty(difficulty uint, elapsed uint64, expected uint64) uint {
+	if elapsed*retargetThreshold < expected && difficulty < MaxMiningDifficulty {
+		return difficulty + 1
+	}
+
+	if elapsed > expected*retargetThreshold && difficulty > 1 {
+		return difficulty - 1
+	}
+
+	return difficulty
+}
+
+// isBlockHashValid tells whether the block hash satisfies the block target, or its difficulty prior to the TIP-5 fork.
+func (s *State) isBlockHashValid(hash Hash, b Block) bool {
+	if b.Header.Bits != 0 {
+		return IsBlockHashBelowTarget(hash, b.Header.Bits)
+	}
+
+	return IsBlockHashValid(hash, s.difficultyOf(b))
+}
+
+// difficultyOf returns the difficulty the block was mined at, as recorded in its header from the TIP-4 fork on.
+func (s *State) difficultyOf(b Block) uint {
+	if b.Header.Difficulty != 0 {
+		return b.Header.Difficulty
+	}
+
+	return s.miningDifficulty
+}
+
+// trackRetargetInterval remembers when the current retarget interval started, once the block is applied.
+func (s *State) trackRetargetInterval(b Block) {
+	number := b.Header.Number
+
+	if s.forkTIP4 != ForkDisabled && number >= s.forkTIP4 && (number-s.forkTIP4)%s.retargetInterval == 0 {
+		s.retargetStartTime = b.Header.Time
+	}
+}
+
+// targetOf returns the target the block hash had to be lower than.
+func (s *State) targetOf(b Block) *big.Int {
+	if b.Header.Bits != 0 {
+		return CompactToTarget(b.Header.Bits)
+	}
+
+	return difficultyToTarget(s.difficultyOf(b))
+}
+
+// genesisTarget returns the target of the chain's first block.
+func (s *State) genesisTarget() *big.Int {
+	if s.forkTIP4 == 0 {
+		return difficultyToTarget(s.tip4Difficulty)
+	}
+
+	return difficultyToTarget(s.miningDifficulty)
+}
+
+// easiestTargetAt returns the easiest target a block at the height can have, on a chain going through a block of the
+// given target and number: each retarget in between eases the target by one step at most, the TIP-4 fork block
+// starts from the genesis difficulty. Blocks claiming an easier target can't be part of a valid chain.
+func (s *State) easiestTargetAt(target *big.Int, from uint64, number uint64) *big.Int {
+	target = new(big.Int).Set(target)
+	if s.forkTIP4 == ForkDisabled || number < s.forkTIP4 {
+		return target
+	}
+
+	if from < s.forkTIP4 {
+		target = difficultyToTarget(s.tip4Difficulty)
+		from = s.forkTIP4
+	}
+
+	height := s.forkTIP4 + ((from-s.forkTIP4)/s.retargetInterval+1)*s.retargetInterval
+	for ; height > from && height <= number && target.Cmp(maxTarget) < 0; height += s.retargetInterval {
+		if s.forkTIP5 != ForkDisabled && height > s.forkTIP5 {
+			target.Mul(target, big.NewInt(retargetMaxShift))
+		} else {
+			target.Lsh(target, 8)
+		}
+	}
+
+	if target.Cmp(maxTarget) > 0 {
+		return new(big.Int).Set(maxTarget)
+	}
+
+	return target
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestRetargetDifficulty(t *testing.T) {
+	testCases := map[string]struct {
+		difficulty     uint
+		elapsed        uint64
+		wantDifficulty uint
+	}{
+		"on target":               {difficulty: 3, elapsed: 100, wantDifficulty: 3},
+		"slightly too fast":       {difficulty: 3, elapsed: 10, wantDifficulty: 3},
+		"way too fast":            {difficulty: 3, elapsed: 6, wantDifficulty: 4},
+		"slightly too slow":       {difficulty: 3, elapsed: 1000, wantDifficulty: 3},
+		"way too slow":            {difficulty: 3, elapsed: 1601, wantDifficulty: 2},
+		"way too slow at lowest":  {difficulty: 1, elapsed: 10000, wantDifficulty: 1},
+		"way too fast at hardest": {difficulty: MaxMiningDifficulty, elapsed: 1, wantDifficulty: MaxMiningDifficulty},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.wantDifficulty, retargetDifficulty(tc.difficulty, tc.elapsed, 100))
+		})
+	}
+}
+
+func TestState_EasiestTargetAt(t *testing.T) {
+	s := &State{forkTIP4: 10, forkTIP5: 30, tip4Difficulty: 3, retargetInterval: 10}
+	target := difficultyToTarget(4)
+
+	testCases := map[string]struct {
+		from       uint64
+		number     uint64
+		wantTarget *big.Int
+	}{
+		"before TIP-4":              {from: 2, number: 9, wantTarget: target},
+		"TIP-4 genesis difficulty":  {from: 2, number: 10, wantTarget: difficultyToTarget(3)},
+		"same retarget interval":    {from: 11, number: 19, wantTarget: target},
+		"one difficulty retarget":   {from: 11, number: 20, wantTarget: difficultyToTarget(3)},
+		"one bits retarget":         {from: 31, number: 40, wantTarget: new(big.Int).Mul(target, big.NewInt(retargetMaxShift))},
+		"difficulty and bits steps": {from: 25, number: 40, wantTarget: new(big.Int).Mul(difficultyToTarget(3), big.NewInt(retargetMaxShift))},
+		"capped to easiest target":  {from: 11, number: 1000000, wantTarget: maxTarget},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.wantTarget.String(), s.easiestTargetAt(target, tc.from, tc.number).String())
+		})
+	}
+}
+
+func TestState_DifficultyRetargeting(t *testing.T) {
+	forkTIP4 := uint64(1)
+	s, key, sender := newTestState(t, Genesis{
+		ForkTIP1:         0,
+		ForkTIP4:         &forkTIP4,
+		MiningDifficulty: 1,
+		TargetBlockTime:  60,
+		RetargetInterval: 3,
+	})
+	defer utils.RemoveDir(s.dataDir)
+
+	s.snapshotInterval = 2
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	// Prior to the fork, the difficulty is the node setting and isn't recorded in the header
+	block0 := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)})
+	assert.Equal(t, uint(0), block0.Header.Difficulty)
+
+	_, err := s.AddBlock(block0)
+	require.NoError(t, err)
+
+	// The blocks of the first interval are mined within the same second, way faster than the target block time
+	for nonce := uint(2); nonce <= 4; nonce++ {
+		block := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), nonce, ""), key)})
+		block.Header.Time = block0.Header.Time
+		assert.Equal(t, uint(1), block.Header.Difficulty)
+
+		_, err := s.AddBlock(mineTestBlockWithHeader(t, s, block))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, uint(2), s.NextBlockDifficulty())
+
+	// Every node must agree on the difficulty
+	tx := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 5, ""), key)
+	block := mineTestBlock(t, s, sender, []SignedTx{tx})
+	block.Header.Difficulty = 1
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, block))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "difficulty")
+
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx}))
+	require.NoError(t, err)
+
+	// The retarget interval survives a restart from a snapshot taken in its middle
+	expectedDifficulty := s.NextBlockDifficulty()
+	expectedRetargetStart := s.retargetStartTime
+	require.NoError(t, s.Close())
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	assert.Equal(t, expectedDifficulty, restarted.NextBlockDifficulty())
+	assert.Equal(t, expectedRetargetStart, restarted.retargetStartTime)
+}
+
+func TestGenesis_TIP4RequiresRetargetSettings(t *testing.T) {
+	forkTIP4 := uint64(1)
+
+	assert.Error(t, Genesis{ForkTIP4: &forkTIP4, TargetBlockTime: 60, RetargetInterval: 10}.validate())
+	assert.Error(t, Genesis{ForkTIP4: &forkTIP4, MiningDifficulty: 1, TargetBlockTime: 60, RetargetInterval: 1}.validate())
+	assert.NoError(t, Genesis{ForkTIP4: &forkTIP4, MiningDifficulty: 1, TargetBlockTime: 60, RetargetInterval: 10}.validate())
+	assert.NoError(t, Genesis{ForkTIP4: &forkTIP4, MiningDifficulty: MaxMiningDifficulty, TargetBlockTime: 60, RetargetInterval: 10}.validate())
+	assert.Error(t, Genesis{ForkTIP4: &forkTIP4, MiningDifficulty: MaxMiningDifficulty + 1, TargetBlockTime: 60, RetargetInterval: 10}.validate())
+	assert.NoError(t, Genesis{}.validate())
+}
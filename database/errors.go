@@ -0,0 +1,114 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Consensus errors, wrapped by the errors of the TXs and blocks breaking a rule. Check them with errors.Is,
+// or get the details of the typed ones with errors.As.
+var (
+	ErrForgedSignature     = errors.New("forged TX signature")
+	ErrInvalidNonce        = errors.New("invalid TX nonce")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrInvalidGas          = errors.New("invalid TX gas")
+	ErrInvalidValue        = errors.New("invalid TX value")
+	ErrInvalidChainID      = errors.New("invalid TX chain ID")
+	ErrInvalidTxOrder      = errors.New("wrong TXs order")
+	ErrInvalidMultisig     = errors.New("invalid multisig TX")
+
+	ErrBadBlockNumber = errors.New("bad block number")
+	ErrBadParent      = errors.New("bad block parent")
+	ErrBadPoW         = errors.New("invalid block hash")
+	ErrBadDifficulty  = errors.New("bad block difficulty")
+	ErrBadBlockTime   = errors.New("bad block time")
+	ErrBadTxRoot      = errors.New("bad block TX root")
+	ErrBadStateRoot   = errors.New("bad block state root")
+	ErrBlockTooLarge  = errors.New("block exceeds the limits")
+	ErrBadCoinbase    = errors.New("bad block coinbase")
+)
+
+// ForgedSignatureError is a TX not signed by its sender, Err telling why a malformed signature couldn't be verified.
+type ForgedSignatureError struct {
+	Account common.Address
+	Err     error
+}
+
+func (e *ForgedSignatureError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("wrong tx. sender '%s' is forged. %s", e.Account.String(), e.Err.Error())
+	}
+
+	return fmt.Sprintf("wrong tx. sender '%s' is forged", e.Account.String())
+}
+
+func (e *ForgedSignatureError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ForgedSignatureError) Is(target error) bool {
+	return target == ErrForgedSignature
+}
+
+// InvalidNonceError is a TX not following its sender's previous TX.
+type InvalidNonceError struct {
+	Account  common.Address
+	Expected uint
+	Actual   uint
+}
+
+func (e *InvalidNonceError) Error() string {
+	return fmt.Sprintf("wrong tx. sender '%s' next nonce must be '%d', not '%d'", e.Account.String(), e.Expected, e.Actual)
+}
+
+func (e *InvalidNonceError) Is(target error) bool {
+	return target == ErrInvalidNonce
+}
+
+// InsufficientBalanceError is a TX costing more than its sender's balance.
+type InsufficientBalanceError struct {
+	Account common.Address
+	Balance *big.Int
+	Cost    *big.Int
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("wrong tx. sender '%s' balance is %s TBB. tx cost is %s TBB", e.Account.String(), e.Balance, e.Cost)
+}
+
+func (e *InsufficientBalanceError) Is(target error) bool {
+	return target == ErrInsufficientBalance
+}
+
+// BlockError is a block breaking a consensus rule, Err being the rule's error.
+//
+// Expected and Actual are set when the rule requires a value the block doesn't have, e.g. the parent Hash,
+// the uint64 block number or the uint32 bits. For the rules setting a bound, e.g. the block limits or the block time,
+// Expected is the bound.
+type BlockError struct {
+	Number   uint64
+	Err      error
+	Expected interface{}
+	Actual   interface{}
+	msg      string
+}
+
+func newBlockError(number uint64, err error, format string, a ...interface{}) error {
+	return &BlockError{Number: number, Err: err, msg: fmt.Sprintf(format, a...)}
+}
+
+// newBlockMismatchError returns the error of a block value not matching the one the rule expects.
+func newBlockMismatchError(number uint64, err error, expected interface{}, actual interface{}, format string, a ...interface{}) error {
+	return &BlockError{Number: number, Err: err, Expected: expected, Actual: actual, msg: fmt.Sprintf(format, a...)}
+}
+
+func (e *BlockError) Error() string {
+	return e.msg
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestState_ValidateTxErrors(t *testing.T) {
+	forkTIP8 := uint64(0)
+	s, key, sender := newTestState(t, Genesis{ChainID: "tbb-test", ForkTIP1: 0, ForkTIP8: &forkTIP8})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	newTx := func(value uint, nonce uint, gas uint, chainID string) Tx {
+		tx := NewTx(sender, receiver, amount(value), nonce, amount(gas), amount(TxGasPriceDefault), "")
+		tx.ChainID = chainID
+
+		return tx
+	}
+
+	forged := signTestTx(t, newTx(10, 1, TxGas, "tbb-test"), key)
+	forged.Value = big.NewInt(20)
+
+	malformedSig := signTestTx(t, newTx(10, 1, TxGas, "tbb-test"), key)
+	malformedSig.Sig = []byte{1, 2, 3}
+
+	testCases := map[string]struct {
+		tx      SignedTx
+		wantErr error
+	}{
+		"forged signature": {
+			tx:      forged,
+			wantErr: ErrForgedSignature,
+		},
+		"malformed signature": {
+			tx:      malformedSig,
+			wantErr: ErrForgedSignature,
+		},
+		"invalid nonce": {
+			tx:      signTestTx(t, newTx(10, 2, TxGas, "tbb-test"), key),
+			wantErr: ErrInvalidNonce,
+		},
+		"invalid gas": {
+			tx:      signTestTx(t, newTx(10, 1, 1, "tbb-test"), key),
+			wantErr: ErrInvalidGas,
+		},
+		"invalid chain ID": {
+			tx:      signTestTx(t, newTx(10, 1, TxGas, "tbb-other"), key),
+			wantErr: ErrInvalidChainID,
+		},
+		"insufficient balance": {
+			tx:      signTestTx(t, newTx(1000000, 1, TxGas, "tbb-test"), key),
+			wantErr: ErrInsufficientBalance,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := s.ValidateTx(tc.tx)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tc.wantErr), err.Error())
+		})
+	}
+
+	var nonceErr *InvalidNonceError
+	require.True(t, errors.As(s.ValidateTx(testCases["invalid nonce"].tx), &nonceErr))
+	assert.Equal(t, sender, nonceErr.Account)
+	assert.Equal(t, uint(1), nonceErr.Expected)
+	assert.Equal(t, uint(2), nonceErr.Actual)
+
+	var balanceErr *InsufficientBalanceError
+	require.True(t, errors.As(s.ValidateTx(testCases["insufficient balance"].tx), &balanceErr))
+	assert.Equal(t, "1000000", balanceErr.Balance.String())
+	assert.Equal(t, "1000021", balanceErr.Cost.String())
+
+	assert.NoError(t, s.ValidateTx(signTestTx(t, newTx(10, 1, TxGas, "tbb-test"), key)))
+}
+
+func TestState_AddBlockErrors(t *testing.T) {
+	s, _, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	_, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{}))
+	require.NoError(t, err)
+
+	_, err = s.AddBlock(mineTestBlockOn(t, s, Hash{1}, s.NextBlockNumber(), sender, []SignedTx{}))
+	assert.True(t, errors.Is(err, ErrUnknownParent))
+
+	_, err = s.AddBlock(mineTestBlockOn(t, s, s.LatestBlockHash(), s.NextBlockNumber()+1, sender, []SignedTx{}))
+	assert.True(t, errors.Is(err, ErrBadBlockNumber))
+
+	var blockErr *BlockError
+	require.True(t, errors.As(err, &blockErr))
+	assert.Equal(t, uint64(2), blockErr.Number)
+	assert.Equal(t, uint64(1), blockErr.Expected)
+	assert.Equal(t, uint64(2), blockErr.Actual)
+
+	// a block hash not meeting the difficulty
+	block := mineTestBlock(t, s, sender, []SignedTx{})
+	for {
+		hash, err := block.Hash()
+		require.NoError(t, err)
+
+		if !s.isBlockHashValid(hash, block) {
+			break
+		}
+
+		block.Header.Nonce++
+	}
+
+	_, err = s.AddBlock(block)
+	assert.True(t, errors.Is(err, ErrBadPoW))
+}
+
+func TestState_ValidatePendingTx(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	tx1 := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)
+	tx2 := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 2, ""), key)
+	pending := []SignedTx{tx1}
+
+	// The mined state alone only accepts the first TX
+	assert.True(t, errors.Is(s.ValidateTx(tx2), ErrInvalidNonce))
+	assert.NoError(t, s.ValidatePendingTx(tx2, pending))
+	assert.NoError(t, s.ValidatePendingTx(tx1, pending))
+
+	nonce, err := s.GetNextNonceAfterPending(sender, pending)
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), nonce)
+
+	gapped := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 3, ""), key)
+	assert.True(t, errors.Is(s.ValidatePendingTx(gapped, pending), ErrInvalidNonce))
+
+	// The pending TXs costs are taken from the balance
+	tooExpensive := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(1000000-TxGas-5), 2, ""), key)
+	assert.NoError(t, s.ValidateTx(signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(1000000-TxGas-5), 1, ""), key)))
+	assert.True(t, errors.Is(s.ValidatePendingTx(tooExpensive, pending), ErrInsufficientBalance))
+}
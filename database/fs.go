@@ -1,17 +1,30 @@
 package database
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"the-blockchain-bar/utils"
+
+	"github.com/prometheus/tsdb/fileutil"
 )
 
+// ErrDataDirLocked is returned when another process, e.g. a running node, already uses the data dir.
+var ErrDataDirLocked = errors.New("data dir is in use by another process")
+
+// InitDataDirIfNotExists creates the data dir with the given genesis, or upgrades the layout of an existing one.
 func InitDataDirIfNotExists(dataDir string, genesis []byte) error {
 	if utils.FileExist(getGenesisJsonFilePath(dataDir)) {
-		return nil
+		return MigrateDataDir(dataDir)
 	}
 
+	return initDataDir(dataDir, genesis, BackendFile)
+}
+
+func initDataDir(dataDir string, genesis []byte, backend string) error {
 	dbDir := getDatabaseDirPath(dataDir)
 	if err := os.MkdirAll(dbDir, os.ModePerm); err != nil {
 		return err
@@ -21,18 +34,87 @@ func InitDataDirIfNotExists(dataDir string, genesis []byte) error {
 		return err
 	}
 
-	blocks := getBlocksDbFilePath(dataDir)
-	if err := writeEmptyBlocksDbToDisk(blocks); err != nil {
+	store, err := newBlockStoreAt(getBlockStorePath(dataDir, backend), backend)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Close(); err != nil {
+		return err
+	}
+
+	return writeDataDirVersion(dataDir, DataDirVersion)
+}
+
+// lockDataDir takes the exclusive lock of the data dir database, held until released, so no other process
+// reads a block while it's being written, or recovers a record another process is still appending.
+func lockDataDir(dataDir string) (fileutil.Releaser, error) {
+	lock, _, err := fileutil.Flock(getDataDirLockFilePath(dataDir))
+	if err != nil {
+		return nil, fmt.Errorf("%w. stop the node using '%s' or query its HTTP API instead. %s", ErrDataDirLocked, dataDir, err.Error())
+	}
+
+	return lock, nil
+}
+
+// writeFileAtomic writes the file to a temporary file first and then renames it,
+// so a crash never leaves a half-written file behind.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	defer d.Close()
+
+	return d.Sync()
 }
 
 func getDatabaseDirPath(dataDir string) string {
 	return filepath.Join(dataDir, "database")
 }
 
+func getDataDirLockFilePath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "LOCK")
+}
+
+func getReorgJournalFilePath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "reorg.journal")
+}
+
 func getGenesisJsonFilePath(dataDir string) string {
 	return filepath.Join(getDatabaseDirPath(dataDir), "genesis.json")
 }
@@ -41,6 +123,19 @@ func getBlocksDbFilePath(dataDir string) string {
 	return filepath.Join(getDatabaseDirPath(dataDir), "block.db")
 }
 
+func getTxIndexFilePath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "tx.idx")
+}
+
+func getBlocksLevelDBDirPath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "block.ldb")
+}
+
+// getBlocksIndexFilePath returns the path of the index of a JSON-lines blocks file, e.g. block.db -> block.idx
+func getBlocksIndexFilePath(dbFilePath string) string {
+	return strings.TrimSuffix(dbFilePath, filepath.Ext(dbFilePath)) + ".idx"
+}
+
 func writeEmptyBlocksDbToDisk(path string) error {
 	return ioutil.WriteFile(path, []byte(""), os.ModePerm)
 }
@@ -3,7 +3,11 @@ package database
 import (
 	_ "embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"math"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -12,9 +16,51 @@ import (
 var genesisJson string
 
 type Genesis struct {
-	Balances map[common.Address]uint `json:"balances"`
-	Symbol   string                  `json:"symbol"`
-	ForkTIP1 uint64                  `json:"fork_tip_1"`
+	Balances map[common.Address]*big.Int `json:"balances"`
+	Symbol   string                      `json:"symbol"`
+	ChainID  string                      `json:"chain_id"`
+	ForkTIP1 uint64                      `json:"fork_tip_1"`
+
+	// Forks activated after TIP-1 are optional, a fork missing from the genesis is never activated
+	ForkTIP2  *uint64 `json:"fork_tip_2,omitempty"`
+	ForkTIP3  *uint64 `json:"fork_tip_3,omitempty"`
+	ForkTIP4  *uint64 `json:"fork_tip_4,omitempty"`
+	ForkTIP5  *uint64 `json:"fork_tip_5,omitempty"`
+	ForkTIP6  *uint64 `json:"fork_tip_6,omitempty"`
+	ForkTIP7  *uint64 `json:"fork_tip_7,omitempty"`
+	ForkTIP8  *uint64 `json:"fork_tip_8,omitempty"`
+	ForkTIP9  *uint64 `json:"fork_tip_9,omitempty"`
+	ForkTIP10 *uint64 `json:"fork_tip_10,omitempty"`
+	ForkTIP11 *uint64 `json:"fork_tip_11,omitempty"`
+
+	// Difficulty retargeting, from the TIP-4 fork on
+	MiningDifficulty uint   `json:"mining_difficulty,omitempty"` // difficulty of the fork block
+	TargetBlockTime  uint64 `json:"target_block_time,omitempty"` // seconds between blocks
+	RetargetInterval uint64 `json:"retarget_interval,omitempty"` // blocks between difficulty adjustments
+
+	// MaxBlockTimeDrift is how far, in seconds, a block time can be ahead of the local clock, DefaultMaxBlockTimeDrift if unset
+	MaxBlockTimeDrift uint64 `json:"max_block_time_drift,omitempty"`
+
+	// Block limits, from the TIP-9 fork on
+	MaxBlockTXs  uint   `json:"max_block_txs,omitempty"`
+	MaxBlockSize uint64 `json:"max_block_size,omitempty"` // bytes of the block TXs, JSON encoded
+
+	// Emission schedule of new coins, BlockReward for every block if unset
+	BlockReward     *uint    `json:"block_reward,omitempty"`     // reward of the genesis block, 0 rewards blocks with their fees only
+	HalvingInterval uint64   `json:"halving_interval,omitempty"` // blocks between reward halvings, never halved if unset
+	MaxSupply       *big.Int `json:"max_supply,omitempty"`       // cap of the genesis balances and all rewards, uncapped if unset
+}
+
+// ForkDisabled is the activation height of forks missing from the genesis.
+const ForkDisabled = math.MaxUint64
+
+// forkHeight returns the height the fork activates at.
+func forkHeight(height *uint64) uint64 {
+	if height == nil {
+		return ForkDisabled
+	}
+
+	return *height
 }
 
 func loadGenesis(path string) (Genesis, error) {
@@ -25,9 +71,68 @@ func loadGenesis(path string) (Genesis, error) {
 		return loadedGenesis, err
 	}
 
-	err = json.Unmarshal(fileContent, &loadedGenesis)
+	if err := json.Unmarshal(fileContent, &loadedGenesis); err != nil {
+		return loadedGenesis, err
+	}
+
+	return loadedGenesis, loadedGenesis.validate()
+}
+
+func (g Genesis) validate() error {
+	for account, balance := range g.Balances {
+		if balance == nil || balance.Sign() < 0 {
+			return fmt.Errorf("the genesis balance of '%s' must be a non-negative amount", account.String())
+		}
+	}
+
+	if g.ForkTIP4 != nil {
+		if g.MiningDifficulty == 0 || g.TargetBlockTime == 0 || g.RetargetInterval < 2 {
+			return errors.New("the TIP-4 fork requires the genesis 'mining_difficulty' and 'target_block_time' to be set and 'retarget_interval' to be at least 2")
+		}
+	}
+
+	if g.MiningDifficulty > MaxMiningDifficulty {
+		return fmt.Errorf("the genesis 'mining_difficulty' can't be higher than %d", MaxMiningDifficulty)
+	}
+
+	if g.ForkTIP8 != nil && g.ChainID == "" {
+		return errors.New("the TIP-8 fork requires the genesis 'chain_id' to be set")
+	}
+
+	if g.ForkTIP9 != nil && g.MaxBlockTXs == 0 && g.MaxBlockSize == 0 {
+		return errors.New("the TIP-9 fork requires the genesis 'max_block_txs' or 'max_block_size' to be set")
+	}
+
+	if g.MaxSupply != nil && g.MaxSupply.Cmp(g.supply()) < 0 {
+		return fmt.Errorf("the genesis 'max_supply' of %s TBB is lower than the %s TBB of its balances", g.MaxSupply, g.supply())
+	}
+
+	return nil
+}
+
+// rewardPolicy returns the emission schedule of the network.
+func (g Genesis) rewardPolicy() RewardPolicy {
+	initialReward := uint(BlockReward)
+	if g.BlockReward != nil {
+		initialReward = *g.BlockReward
+	}
+
+	return RewardPolicy{
+		InitialReward:   initialReward,
+		HalvingInterval: g.HalvingInterval,
+		MaxSupply:       g.MaxSupply,
+		GenesisSupply:   g.supply(),
+	}
+}
+
+// supply sums up the genesis balances.
+func (g Genesis) supply() *big.Int {
+	supply := big.NewInt(0)
+	for _, balance := range g.Balances {
+		supply.Add(supply, balance)
+	}
 
-	return loadedGenesis, err
+	return supply
 }
 
 func writeGenesisToDisk(path string, genesis []byte) error {
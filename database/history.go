@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BalancesAt returns the accounts balances after the main chain block at the height was applied, and the block hash.
+//
+// Only the latest balances are kept in memory, older ones are rebuilt by replaying the blocks
+// from the nearest snapshot below the height, or from genesis. Heights too far from a snapshot
+// fail with ErrHistoryUnavailable rather than replaying most of the chain.
+func (s *State) BalancesAt(height uint64) (map[common.Address]*big.Int, Hash, error) {
+	if !s.hasGenesisBlock || height > s.latestBlock.Header.Number {
+		return nil, Hash{}, fmt.Errorf("%w at height '%d'", ErrBlockNotFound, height)
+	}
+
+	historic := s.copy()
+	if height < s.latestBlock.Header.Number {
+		var err error
+		if historic, err = s.historicState(height + 1); err != nil {
+			return nil, Hash{}, err
+		}
+	}
+
+	return historic.Balances, historic.latestBlockHash, nil
+}
+
+// historicState rebuilds the state prior to the main chain block at the given height,
+// replaying at most maxHistoryReplay blocks from the nearest snapshot.
+func (s *State) historicState(before uint64) (State, error) {
+	historic := s.copy()
+	// a replay of already snapshotted blocks mustn't write, nor prune, snapshots
+	historic.snapshotInterval = 0
+
+	if err := historic.replayMainChain(before, s.maxHistoryReplay); err != nil {
+		return State{}, err
+	}
+
+	return historic, nil
+}
+
+// BalanceAt returns the account balance after the main chain block at the height was applied.
+func (s *State) BalanceAt(account common.Address, height uint64) (*big.Int, error) {
+	balances, _, err := s.BalancesAt(height)
+	if err != nil {
+		return nil, err
+	}
+
+	if balance, ok := balances[account]; ok {
+		return new(big.Int).Set(balance), nil
+	}
+
+	return big.NewInt(0), nil
+}
+
+// BlockHeight returns the height of the main chain block, e.g. to query balances by block hash.
+func (s *State) BlockHeight(blockHash Hash) (uint64, error) {
+	block, err := s.GetBlockByHash(blockHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return block.Header.Number, nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestState_BalancesAt(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	// history is rebuilt both from snapshots and from genesis
+	s.snapshotInterval = 2
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	history := make([]map[common.Address]*big.Int, 0)
+	hashes := make([]Hash, 0)
+	for nonce := uint(1); nonce <= 5; nonce++ {
+		tx := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), nonce, ""), key)
+		hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx}))
+		require.NoError(t, err)
+
+		balances := make(map[common.Address]*big.Int)
+		for account, balance := range s.Balances {
+			balances[account] = balance
+		}
+
+		history = append(history, balances)
+		hashes = append(hashes, hash)
+	}
+
+	latestBalances := s.Balance(receiver)
+
+	for height := range history {
+		balances, hash, err := s.BalancesAt(uint64(height))
+		require.NoError(t, err)
+		assertBalances(t, history[height], balances)
+		assert.Equal(t, hashes[height], hash)
+
+		blockHeight, err := s.BlockHeight(hash)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(height), blockHeight)
+	}
+
+	balance, err := s.BalanceAt(receiver, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "20", balance.String())
+
+	// querying history leaves the latest state untouched
+	assert.Equal(t, latestBalances, s.Balance(receiver))
+	assert.Equal(t, hashes[len(hashes)-1], s.LatestBlockHash())
+
+	_, err = s.BalanceAt(receiver, uint64(len(history)))
+	assert.True(t, errors.Is(err, ErrBlockNotFound))
+}
+
+func TestState_BalancesAtKeepsSparseHistory(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	s.snapshotInterval = 2
+	s.historyInterval = 4
+	s.maxHistoryReplay = 4
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	history := make([]map[common.Address]*big.Int, 0)
+	for nonce := uint(1); nonce <= 13; nonce++ {
+		tx := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), nonce, ""), key)
+		_, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx}))
+		require.NoError(t, err)
+
+		balances := make(map[common.Address]*big.Int)
+		for account, balance := range s.Balances {
+			balances[account] = balance
+		}
+
+		history = append(history, balances)
+	}
+
+	// the newest snapshots, and every historyInterval blocks
+	heights, err := listSnapshots(s.dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{12, 10, 8, 4}, heights)
+
+	for height := range history {
+		balances, _, err := s.BalancesAt(uint64(height))
+		require.NoError(t, err)
+		assertBalances(t, history[height], balances)
+	}
+
+	// without a snapshot close enough, the history isn't rebuilt from genesis
+	require.NoError(t, os.Remove(getSnapshotFilePath(s.dataDir, 4)))
+
+	_, _, err = s.BalancesAt(7)
+	assert.True(t, errors.Is(err, ErrHistoryUnavailable))
+
+	balances, _, err := s.BalancesAt(3)
+	require.NoError(t, err)
+	assertBalances(t, history[3], balances)
+}
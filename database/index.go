@@ -0,0 +1,255 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// blockIndex locates blocks inside block.db without scanning the file.
+//
+// It maps every block hash to the position of its record in block.db and every height to its hash.
+// The index is persisted next to block.db as JSON lines, one entry per block,
+// and rebuilt from block.db whenever it's missing or doesn't match the database file.
+type blockIndex struct {
+	byHash   map[Hash]blockIndexEntry
+	byHeight []Hash
+	dbSize   int64 // block.db bytes covered by the index
+
+	path string
+	file *os.File
+}
+
+type blockIndexEntry struct {
+	Hash   Hash   `json:"hash"`
+	Number uint64 `json:"number"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"` // record length, including the trailing new line
+}
+
+// openBlockIndex loads the index persisted at indexPath, rebuilding it from dbFile if necessary.
+func openBlockIndex(indexPath string, dbFile *os.File) (*blockIndex, error) {
+	dbInfo, err := dbFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := loadBlockIndex(indexPath)
+	if err != nil || idx.dbSize != dbInfo.Size() {
+		fmt.Printf("rebuilding blocks index %s\n", indexPath)
+
+		if idx, err = rebuildBlockIndex(indexPath, dbFile); err != nil {
+			return nil, err
+		}
+	}
+
+	idx.path = indexPath
+	idx.file, err = os.OpenFile(indexPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+func newBlockIndex() *blockIndex {
+	return &blockIndex{
+		byHash:   make(map[Hash]blockIndexEntry),
+		byHeight: make([]Hash, 0),
+	}
+}
+
+func loadBlockIndex(indexPath string) (*blockIndex, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	idx := newBlockIndex()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry blockIndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		if err := idx.add(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+func rebuildBlockIndex(indexPath string, dbFile *os.File) (*blockIndex, error) {
+	idx := newBlockIndex()
+
+	if _, err := dbFile.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	offset := int64(0)
+	reader := bufio.NewReader(dbFile)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			break
+		}
+
+		blockFs, err := decodeBlockRecord(line)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := blockIndexEntry{blockFs.Key, blockFs.Value.Header.Number, offset, int64(len(line))}
+		if err := idx.add(entry); err != nil {
+			return nil, err
+		}
+
+		offset += entry.Length
+	}
+
+	if err := idx.write(indexPath); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// write persists all the index entries to the given path, replacing its content.
+func (idx *blockIndex) write(indexPath string) error {
+	entries := make([]byte, 0)
+
+	for _, hash := range idx.byHeight {
+		entryJson, err := json.Marshal(idx.byHash[hash])
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, append(entryJson, '\n')...)
+	}
+
+	return ioutil.WriteFile(indexPath, entries, 0600)
+}
+
+// add registers the entry of the block stored right after the last indexed one.
+func (idx *blockIndex) add(entry blockIndexEntry) error {
+	if err := idx.validate(entry); err != nil {
+		return err
+	}
+
+	idx.byHash[entry.Hash] = entry
+	idx.byHeight = append(idx.byHeight, entry.Hash)
+	idx.dbSize += entry.Length
+
+	return nil
+}
+
+// validate checks the entry is of a new block, stored right after the last indexed one.
+func (idx *blockIndex) validate(entry blockIndexEntry) error {
+	if entry.Number != uint64(len(idx.byHeight)) {
+		return fmt.Errorf("blocks index expected block number '%d' not '%d'", len(idx.byHeight), entry.Number)
+	}
+
+	if entry.Offset != idx.dbSize {
+		return fmt.Errorf("blocks index expected block '%x' at offset '%d' not '%d'", entry.Hash, idx.dbSize, entry.Offset)
+	}
+
+	if _, ok := idx.byHash[entry.Hash]; ok {
+		return fmt.Errorf("blocks index already contains block '%x'", entry.Hash)
+	}
+
+	return nil
+}
+
+// nextEntry returns the entry of a block to be appended to block.db, failing if the index can't add it.
+func (idx *blockIndex) nextEntry(hash Hash, number uint64, length int64) (blockIndexEntry, error) {
+	entry := blockIndexEntry{hash, number, idx.dbSize, length}
+
+	return entry, idx.validate(entry)
+}
+
+// append indexes and persists the entry of a block just written to block.db.
+func (idx *blockIndex) append(entry blockIndexEntry) error {
+	entryJson, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := idx.add(entry); err != nil {
+		return err
+	}
+
+	_, err = idx.file.Write(append(entryJson, '\n'))
+
+	return err
+}
+
+// truncateFrom removes the entries of all blocks from the given height up.
+func (idx *blockIndex) truncateFrom(height uint64) error {
+	from, ok := idx.entryByHeight(height)
+	if !ok {
+		return nil
+	}
+
+	for _, hash := range idx.byHeight[height:] {
+		delete(idx.byHash, hash)
+	}
+
+	idx.byHeight = idx.byHeight[:height]
+	idx.dbSize = from.Offset
+
+	if err := idx.file.Close(); err != nil {
+		return err
+	}
+
+	if err := idx.write(idx.path); err != nil {
+		return err
+	}
+
+	var err error
+	idx.file, err = os.OpenFile(idx.path, os.O_APPEND|os.O_WRONLY, 0600)
+
+	return err
+}
+
+func (idx *blockIndex) entryByHash(hash Hash) (blockIndexEntry, bool) {
+	entry, ok := idx.byHash[hash]
+
+	return entry, ok
+}
+
+func (idx *blockIndex) entryByHeight(height uint64) (blockIndexEntry, bool) {
+	if height >= uint64(len(idx.byHeight)) {
+		return blockIndexEntry{}, false
+	}
+
+	return idx.byHash[idx.byHeight[height]], true
+}
+
+func (idx *blockIndex) close() error {
+	if idx.file == nil {
+		return nil
+	}
+
+	return idx.file.Close()
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestBlockIndex(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_index_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	dbPath := filepath.Join(dataDir, "block.db")
+	indexPath := getBlocksIndexFilePath(dbPath)
+
+	// Persist a few legacy blocks without an index, like a data dir created by an older node version
+	blocks := make([]BlockFS, 0)
+	records := make([]byte, 0)
+	parent := Hash{}
+	for i := uint64(0); i < 5; i++ {
+		block := NewBlock(parent, i, uint32(i), i, NewAccount(""), []SignedTx{})
+		hash, err := block.Hash()
+		require.NoError(t, err)
+
+		blockFsJson, err := json.Marshal(BlockFS{Key: hash, Value: block})
+		require.NoError(t, err)
+
+		records = append(records, append(blockFsJson, '\n')...)
+		blocks = append(blocks, BlockFS{Key: hash, Value: block})
+		parent = hash
+	}
+	require.NoError(t, ioutil.WriteFile(dbPath, records, 0600))
+
+	// The missing index gets rebuilt from block.db
+	store, err := openFileBlockStore(dbPath)
+	require.NoError(t, err)
+	assert.True(t, utils.FileExist(indexPath))
+
+	for height, blockFs := range blocks {
+		byHeight, ok := store.index.entryByHeight(uint64(height))
+		assert.True(t, ok)
+		assert.Equal(t, blockFs.Key, byHeight.Hash)
+
+		byHash, ok := store.index.entryByHash(blockFs.Key)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(height), byHash.Number)
+
+		read, err := store.read(byHash)
+		assert.NoError(t, err)
+		assert.Equal(t, blockFs.Key, read.Key)
+	}
+
+	_, ok := store.index.entryByHeight(uint64(len(blocks)))
+	assert.False(t, ok)
+
+	// Appended blocks are indexed and persisted, picked up on the next open without a rebuild
+	blocks = append(blocks, appendTestBlocksOn(t, store, parent, uint64(len(blocks)), 2)...)
+	require.NoError(t, store.Close())
+
+	loadedIdx, err := loadBlockIndex(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, store.index.byHash, loadedIdx.byHash)
+	assert.Equal(t, store.index.byHeight, loadedIdx.byHeight)
+
+	store, err = openFileBlockStore(dbPath)
+	require.NoError(t, err)
+	assertStoreContains(t, store, blocks)
+
+	// Truncated blocks are removed from the persisted index too
+	require.NoError(t, store.TruncateFrom(4))
+	require.NoError(t, store.Close())
+
+	loadedIdx, err = loadBlockIndex(indexPath)
+	require.NoError(t, err)
+	assert.Len(t, loadedIdx.byHeight, 4)
+	_, ok = loadedIdx.entryByHash(blocks[4].Key)
+	assert.False(t, ok)
+
+	// An index not matching block.db is rebuilt
+	require.NoError(t, ioutil.WriteFile(indexPath, []byte{}, 0600))
+	store, err = openFileBlockStore(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, loadedIdx.byHash, store.index.byHash)
+	assertStoreContains(t, store, blocks[:4])
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// The TXs of a block are committed to in its header, from the TIP-2 fork on, as the root of a Merkle tree
+// whose leaves are the TX hashes in the block order.
+//
+// Leaves and inner nodes are hashed with different prefixes so an inner node can't be passed off as a TX,
+// and the odd node of a level is promoted to the next level as is, instead of being paired with a copy of itself.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleProofStep is a sibling hash on the path from a TX to the Merkle root.
+type MerkleProofStep struct {
+	Hash Hash `json:"hash"`
+	Left bool `json:"left"` // whether the sibling is on the left of the path
+}
+
+// TxProof proves a TX is included in a block, without the block's other TXs.
+type TxProof struct {
+	TxHash Hash              `json:"tx_hash"`
+	Path   []MerkleProofStep `json:"path"`
+}
+
+// TxRoot returns the Merkle root of the TXs. A block without TXs has an empty root.
+func TxRoot(txs []SignedTx) (Hash, error) {
+	leaves, err := merkleLeaves(txs)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	levels := merkleLevels(leaves)
+
+	return levels[len(levels)-1][0], nil
+}
+
+// NewTxProof builds the inclusion proof of the TX in the given TXs.
+func NewTxProof(txs []SignedTx, txHash Hash) (TxProof, error) {
+	leaves, err := merkleLeaves(txs)
+	if err != nil {
+		return TxProof{}, err
+	}
+
+	index := -1
+	for i, tx := range txs {
+		hash, err := tx.Hash()
+		if err != nil {
+			return TxProof{}, err
+		}
+
+		if hash == txHash {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		return TxProof{}, fmt.Errorf("%w '%x' in block TXs", ErrTxNotFound, txHash)
+	}
+
+	proof := TxProof{TxHash: txHash, Path: make([]MerkleProofStep, 0)}
+
+	levels := merkleLevels(leaves)
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Path = append(proof.Path, MerkleProofStep{Hash: level[sibling], Left: sibling < index})
+		}
+
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// VerifyTxProof checks the proof leads from its TX to the given root, taken from a block header.
+func VerifyTxProof(proof TxProof, txRoot Hash) bool {
+	hash := merkleLeafHash(proof.TxHash)
+
+	for _, step := range proof.Path {
+		if step.Left {
+			hash = merkleNodeHash(step.Hash, hash)
+		} else {
+			hash = merkleNodeHash(hash, step.Hash)
+		}
+	}
+
+	return hash == txRoot
+}
+
+func merkleLeaves(txs []SignedTx) ([]Hash, error) {
+	leaves := make([]Hash, len(txs))
+
+	for i, tx := range txs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		leaves[i] = merkleLeafHash(txHash)
+	}
+
+	return leaves, nil
+}
+
+// merkleLevels returns the tree levels, from the leaves up to the single root.
+func merkleLevels(leaves []Hash) [][]Hash {
+	if len(leaves) == 0 {
+		return [][]Hash{{Hash{}}}
+	}
+
+	levels := [][]Hash{leaves}
+
+	for level := leaves; len(level) > 1; {
+		next := make([]Hash, 0, (len(level)+1)/2)
+
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+
+			next = append(next, merkleNodeHash(level[i], level[i+1]))
+		}
+
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels
+}
+
+func merkleLeafHash(txHash Hash) Hash {
+	return sha256.Sum256(append([]byte{merkleLeafPrefix}, txHash[:]...))
+}
+
+func merkleNodeHash(left, right Hash) Hash {
+	data := make([]byte, 0, 1+2*len(left))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+
+	return sha256.Sum256(data)
+}
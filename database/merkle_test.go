@@ -0,0 +1,45 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestTxProof(t *testing.T) {
+	for _, txsCount := range []int{1, 2, 3, 4, 5, 7, 8} {
+		txs := make([]SignedTx, txsCount)
+		for i := range txs {
+			txs[i] = NewSignedTx(NewTx(NewAccount("0x09ee50f2f37fcba1845de6fe5c762e83e65e755c"), NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8"), big.NewInt(1), uint(i+1), amount(TxGas), amount(TxGasPriceDefault), ""), []byte{})
+		}
+
+		txRoot, err := TxRoot(txs)
+		require.NoError(t, err)
+
+		for i, tx := range txs {
+			txHash, err := tx.Hash()
+			require.NoError(t, err)
+
+			proof, err := NewTxProof(txs, txHash)
+			require.NoError(t, err)
+			assert.True(t, VerifyTxProof(proof, txRoot), "proof of TX %d out of %d", i, txsCount)
+
+			// A proof must not verify against another root or for another TX
+			assert.False(t, VerifyTxProof(proof, Hash{1}))
+
+			if len(proof.Path) > 0 {
+				proof.Path[0].Left = !proof.Path[0].Left
+				assert.False(t, VerifyTxProof(proof, txRoot), "tampered proof of TX %d out of %d", i, txsCount)
+			}
+		}
+	}
+
+	_, err := NewTxProof([]SignedTx{}, Hash{1})
+	assert.Error(t, err)
+
+	emptyRoot, err := TxRoot([]SignedTx{})
+	require.NoError(t, err)
+	assert.True(t, emptyRoot.IsEmpty())
+}
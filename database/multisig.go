@@ -0,0 +1,162 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// From the TIP-11 fork on, multisig accounts spend only with the signatures of M of their N owners.
+//
+// A multisig account address is derived from its policy, the threshold M and the owners, so the account doesn't need
+// to be registered on chain: it receives TBB as any other account, and the TXs it sends carry the policy next to
+// the owners' signatures of the TX.
+
+// MaxMultisigOwners bounds the signatures to verify per TX.
+const MaxMultisigOwners = 16
+
+// multisigAddressPrefix keeps multisig addresses apart from the ones derived from a public key.
+var multisigAddressPrefix = []byte("tbb-multisig")
+
+// MultisigPolicy is the M-of-N set of owners of a multisig account.
+type MultisigPolicy struct {
+	Threshold uint             `json:"threshold"`
+	Owners    []common.Address `json:"owners"`
+}
+
+// NewMultisigPolicy returns the policy of the threshold of the owners signatures, in any order.
+func NewMultisigPolicy(threshold uint, owners []common.Address) (MultisigPolicy, error) {
+	sorted := make([]common.Address, len(owners))
+	copy(sorted, owners)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Bytes(), sorted[j].Bytes()) < 0
+	})
+
+	policy := MultisigPolicy{Threshold: threshold, Owners: sorted}
+
+	return policy, policy.Validate()
+}
+
+// Validate checks the policy is a valid M-of-N set of owners, sorted by address.
+func (p MultisigPolicy) Validate() error {
+	if len(p.Owners) == 0 || len(p.Owners) > MaxMultisigOwners {
+		return fmt.Errorf("%w. it must have 1 to %d owners, not %d", ErrInvalidMultisig, MaxMultisigOwners, len(p.Owners))
+	}
+
+	if p.Threshold == 0 || p.Threshold > uint(len(p.Owners)) {
+		return fmt.Errorf("%w. the threshold must be 1 to %d, not %d", ErrInvalidMultisig, len(p.Owners), p.Threshold)
+	}
+
+	for i := 1; i < len(p.Owners); i++ {
+		if bytes.Compare(p.Owners[i-1].Bytes(), p.Owners[i].Bytes()) >= 0 {
+			return fmt.Errorf("%w. the owners must be distinct and sorted by address", ErrInvalidMultisig)
+		}
+	}
+
+	return nil
+}
+
+// Address returns the multisig account address, the last 20 bytes of the Keccak-256 of the policy.
+func (p MultisigPolicy) Address() common.Address {
+	data := append([]byte{}, multisigAddressPrefix...)
+	data = append(data, byte(p.Threshold))
+	for _, owner := range p.Owners {
+		data = append(data, owner.Bytes()...)
+	}
+
+	return common.BytesToAddress(crypto.Keccak256(data)[12:])
+}
+
+// IsOwner tells whether the account is one of the policy owners.
+func (p MultisigPolicy) IsOwner(account common.Address) bool {
+	for _, owner := range p.Owners {
+		if owner == account {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Multisig is the policy of the multisig account sending a TX and the signatures of its owners collected so far.
+type Multisig struct {
+	MultisigPolicy
+	Sigs [][]byte `json:"signatures"`
+}
+
+// NewMultisigTx returns the TX sent by the multisig account of the policy, without signatures yet.
+func NewMultisigTx(tx Tx, policy MultisigPolicy) (SignedTx, error) {
+	if err := policy.Validate(); err != nil {
+		return SignedTx{}, err
+	}
+
+	if tx.From != policy.Address() {
+		return SignedTx{}, fmt.Errorf("%w. the TX sender '%s' isn't the policy account '%s'", ErrInvalidMultisig, tx.From.String(), policy.Address().String())
+	}
+
+	return SignedTx{Tx: tx, Sig: []byte{}, Multisig: &Multisig{MultisigPolicy: policy, Sigs: [][]byte{}}}, nil
+}
+
+// IsMultisig tells whether the TX is sent by a multisig account.
+func (t SignedTx) IsMultisig() bool {
+	return t.Multisig != nil
+}
+
+// MultisigSigners returns the owners who signed the multisig TX, in the order of their signatures.
+func (t SignedTx) MultisigSigners() ([]common.Address, error) {
+	if t.Multisig == nil {
+		return nil, fmt.Errorf("%w. the TX isn't sent by a multisig account", ErrInvalidMultisig)
+	}
+
+	txHash, err := t.Tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	signers := make([]common.Address, 0, len(t.Multisig.Sigs))
+	for _, sig := range t.Multisig.Sigs {
+		signer, err := recoverSigner(txHash, sig)
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, signer)
+	}
+
+	return signers, nil
+}
+
+// isMultisigAuthentic verifies the TX is sent by the multisig account of its policy,
+// with at least the threshold of signatures, all by distinct owners.
+func (t SignedTx) isMultisigAuthentic() (bool, error) {
+	if err := t.Multisig.Validate(); err != nil {
+		return false, err
+	}
+
+	if len(t.Multisig.Sigs) > len(t.Multisig.Owners) {
+		return false, fmt.Errorf("%w. %d signatures for %d owners", ErrInvalidMultisig, len(t.Multisig.Sigs), len(t.Multisig.Owners))
+	}
+
+	if t.From != t.Multisig.Address() {
+		return false, nil
+	}
+
+	signers, err := t.MultisigSigners()
+	if err != nil {
+		return false, err
+	}
+
+	signed := make(map[common.Address]bool)
+	for _, signer := range signers {
+		if !t.Multisig.IsOwner(signer) || signed[signer] {
+			return false, nil
+		}
+
+		signed[signer] = true
+	}
+
+	return uint(len(signed)) >= t.Multisig.Threshold, nil
+}
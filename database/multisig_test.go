@@ -0,0 +1,149 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestMultisigPolicy(t *testing.T) {
+	owners := []common.Address{
+		NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8"),
+		NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57"),
+		NewAccount("0x22ba1f80452e6220c7cc6ea2d1e3eeddac5f694a"),
+	}
+
+	policy, err := NewMultisigPolicy(2, owners)
+	require.NoError(t, err)
+
+	// The owners order doesn't change the account
+	reversed, err := NewMultisigPolicy(2, []common.Address{owners[2], owners[1], owners[0]})
+	require.NoError(t, err)
+	assert.Equal(t, policy.Address(), reversed.Address())
+
+	other, err := NewMultisigPolicy(1, owners)
+	require.NoError(t, err)
+	assert.NotEqual(t, policy.Address(), other.Address())
+
+	_, err = NewMultisigPolicy(0, owners)
+	assert.True(t, errors.Is(err, ErrInvalidMultisig))
+
+	_, err = NewMultisigPolicy(4, owners)
+	assert.True(t, errors.Is(err, ErrInvalidMultisig))
+
+	_, err = NewMultisigPolicy(2, []common.Address{owners[0], owners[0]})
+	assert.True(t, errors.Is(err, ErrInvalidMultisig))
+
+	_, err = NewMultisigPolicy(1, make([]common.Address, MaxMultisigOwners+1))
+	assert.True(t, errors.Is(err, ErrInvalidMultisig))
+
+	unsorted := MultisigPolicy{Threshold: 2, Owners: owners}
+	assert.True(t, errors.Is(unsorted.Validate(), ErrInvalidMultisig))
+}
+
+func TestState_MultisigFork(t *testing.T) {
+	forkTIP11 := uint64(1)
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0, ForkTIP11: &forkTIP11})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	miner := NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57")
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	ownerKeys := make([]*ecdsa.PrivateKey, 3)
+	owners := make([]common.Address, 3)
+	for i := range ownerKeys {
+		var err error
+		ownerKeys[i], err = crypto.GenerateKey()
+		require.NoError(t, err)
+		owners[i] = crypto.PubkeyToAddress(ownerKeys[i].PublicKey)
+	}
+
+	policy, err := NewMultisigPolicy(2, owners)
+	require.NoError(t, err)
+	treasury := policy.Address()
+
+	// Anyone can fund the multisig account, like any other
+	fund := signTestTx(t, NewBaseTx(sender, treasury, big.NewInt(1000), 1, ""), key)
+
+	spend, err := NewMultisigTx(NewBaseTx(treasury, receiver, big.NewInt(100), 1, ""), policy)
+	require.NoError(t, err)
+	spend = signTestMultisigTx(t, spend, ownerKeys[0], ownerKeys[2])
+
+	// Prior to the fork, multisig TXs are invalid
+	_, err = s.AddBlock(mineTestBlock(t, s, miner, []SignedTx{fund, spend}))
+	assert.True(t, errors.Is(err, ErrInvalidMultisig))
+
+	_, err = s.AddBlock(mineTestBlock(t, s, miner, []SignedTx{fund}))
+	require.NoError(t, err)
+	assert.Equal(t, "1000", s.Balance(treasury).String())
+
+	// From the fork on, the multisig account spends with the threshold of its owners signatures
+	oneSig := signTestMultisigTx(t, spend, ownerKeys[1])
+	oneSig.Multisig.Sigs = oneSig.Multisig.Sigs[2:]
+	assert.True(t, errors.Is(s.ValidateTx(oneSig), ErrForgedSignature))
+
+	outsider, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	withOutsider := spend
+	withOutsider.Multisig = &Multisig{MultisigPolicy: policy, Sigs: [][]byte{spend.Multisig.Sigs[0]}}
+	withOutsider = signTestMultisigTx(t, withOutsider, outsider)
+	assert.True(t, errors.Is(s.ValidateTx(withOutsider), ErrForgedSignature))
+
+	duplicated := spend
+	duplicated.Multisig = &Multisig{MultisigPolicy: policy, Sigs: [][]byte{spend.Multisig.Sigs[0], spend.Multisig.Sigs[0]}}
+	assert.True(t, errors.Is(s.ValidateTx(duplicated), ErrForgedSignature))
+
+	otherPolicy, err := NewMultisigPolicy(1, owners)
+	require.NoError(t, err)
+	wrongPolicy := spend
+	wrongPolicy.Multisig = &Multisig{MultisigPolicy: otherPolicy, Sigs: spend.Multisig.Sigs}
+	assert.True(t, errors.Is(s.ValidateTx(wrongPolicy), ErrForgedSignature))
+
+	withSig := signTestTx(t, spend.Tx, ownerKeys[0])
+	withSig.Multisig = spend.Multisig
+	assert.True(t, errors.Is(s.ValidateTx(withSig), ErrInvalidMultisig))
+
+	require.NoError(t, s.ValidateTx(spend))
+
+	blockHash, err := s.AddBlock(mineTestBlock(t, s, miner, []SignedTx{spend}))
+	require.NoError(t, err)
+	assert.Equal(t, "879", s.Balance(treasury).String())
+	assert.Equal(t, uint(2), s.GetNextNonceByAccount(treasury))
+
+	// The multisig is stored with the block TX
+	mined, err := s.GetBlockByHash(blockHash)
+	require.NoError(t, err)
+	require.True(t, mined.TXs[0].IsMultisig())
+	signers, err := mined.TXs[0].MultisigSigners()
+	require.NoError(t, err)
+	assert.Equal(t, []common.Address{owners[0], owners[2]}, signers)
+}
+
+// signTestMultisigTx adds the owners' signatures to the multisig TX.
+func signTestMultisigTx(t *testing.T, tx SignedTx, keys ...*ecdsa.PrivateKey) SignedTx {
+	txJson, err := tx.Tx.Encode()
+	require.NoError(t, err)
+	txHash := sha256.Sum256(txJson)
+
+	sigs := append([][]byte{}, tx.Multisig.Sigs...)
+	for _, key := range keys {
+		sig, err := crypto.Sign(txHash[:], key)
+		require.NoError(t, err)
+		sigs = append(sigs, sig)
+	}
+
+	multisig := *tx.Multisig
+	multisig.Sigs = sigs
+	tx.Multisig = &multisig
+
+	return tx
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// ReceiptStatusApplied is the status of every receipt, a TX failing to apply invalidates the whole block.
+const ReceiptStatusApplied = uint(1)
+
+// Receipt records how a main chain TX was applied, generated as its block is applied and stored with it.
+type Receipt struct {
+	TxHash      Hash     `json:"tx_hash"`
+	BlockHash   Hash     `json:"block_hash"`
+	BlockNumber uint64   `json:"block_number"`
+	Index       uint     `json:"index"` // position of the TX in the block
+	Status      uint     `json:"status"`
+	IsTIP1Fork  bool     `json:"tip1"`     // the TX paid for its gas, instead of the fixed TxFee
+	Cost        *big.Int `json:"cost"`     // value and fee debited from the sender
+	GasUsed     *big.Int `json:"gas_used"` // zero prior to TIP-1
+	GasPrice    *big.Int `json:"gas_price"`
+	Fee         *big.Int `json:"fee"`          // paid to the block miner
+	FromBalance *big.Int `json:"from_balance"` // balances right after the TX
+	ToBalance   *big.Int `json:"to_balance"`
+}
+
+func newReceipt(tx SignedTx, s *State) (Receipt, error) {
+	txHash, err := tx.Hash()
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	receipt := Receipt{
+		TxHash:      txHash,
+		Status:      ReceiptStatusApplied,
+		IsTIP1Fork:  s.IsTIP1Fork(),
+		Cost:        tx.Cost(s.IsTIP1Fork()),
+		GasUsed:     big.NewInt(0),
+		GasPrice:    big.NewInt(0),
+		Fee:         amount(TxFee),
+		FromBalance: s.Balance(tx.From),
+		ToBalance:   s.Balance(tx.To),
+	}
+
+	if s.IsTIP1Fork() {
+		receipt.GasUsed = amountOrZero(tx.Gas)
+		receipt.GasPrice = amountOrZero(tx.GasPrice)
+		receipt.Fee = tx.GasCost()
+	}
+
+	return receipt, nil
+}
+
+// GetReceipt returns the receipt of the main chain TX with the given hash.
+//
+// Blocks persisted prior to receipts have none stored, their receipts are generated again
+// by replaying the block on top of the state it was mined on.
+func (s *State) GetReceipt(txHash Hash) (Receipt, error) {
+	location, ok := s.txIndex.byHash[txHash]
+	if !ok {
+		return Receipt{}, fmt.Errorf("%w '%x'", ErrTxNotFound, txHash)
+	}
+
+	blockFs, err := s.db.GetByHash(location.BlockHash)
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	receipts := blockFs.Receipts
+	if len(receipts) != len(blockFs.Value.TXs) {
+		historic, err := s.historicState(blockFs.Value.Header.Number)
+		if err != nil {
+			return Receipt{}, err
+		}
+
+		if receipts, err = applyBlock(blockFs.Value, &historic); err != nil {
+			return Receipt{}, err
+		}
+	}
+
+	return receipts[location.Index], nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestState_GetReceipt(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 1})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	legacyTx := signTestTx(t, NewTx(sender, receiver, big.NewInt(10), 1, big.NewInt(0), big.NewInt(0), ""), key)
+	block0Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{legacyTx}))
+	require.NoError(t, err)
+
+	tx1 := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(20), 2, ""), key)
+	tx2 := signTestTx(t, NewTx(sender, receiver, big.NewInt(30), 3, amount(TxGas), big.NewInt(2), ""), key)
+	block1Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx1, tx2}))
+	require.NoError(t, err)
+
+	legacyTxHash, err := legacyTx.Hash()
+	require.NoError(t, err)
+
+	receipt, err := s.GetReceipt(legacyTxHash)
+	require.NoError(t, err)
+	assert.Equal(t, block0Hash, receipt.BlockHash)
+	assert.Equal(t, uint64(0), receipt.BlockNumber)
+	assert.Equal(t, ReceiptStatusApplied, receipt.Status)
+	assert.False(t, receipt.IsTIP1Fork)
+	assert.Equal(t, "60", receipt.Cost.String())
+	assert.Equal(t, "0", receipt.GasUsed.String())
+	assert.Equal(t, "50", receipt.Fee.String())
+	assert.Equal(t, "999940", receipt.FromBalance.String())
+	assert.Equal(t, "10", receipt.ToBalance.String())
+
+	tx2Hash, err := tx2.Hash()
+	require.NoError(t, err)
+
+	receipt, err = s.GetReceipt(tx2Hash)
+	require.NoError(t, err)
+	assert.Equal(t, block1Hash, receipt.BlockHash)
+	assert.Equal(t, uint64(1), receipt.BlockNumber)
+	assert.Equal(t, uint(1), receipt.Index)
+	assert.True(t, receipt.IsTIP1Fork)
+	assert.Equal(t, amount(TxGas).String(), receipt.GasUsed.String())
+	assert.Equal(t, "2", receipt.GasPrice.String())
+	assert.Equal(t, "42", receipt.Fee.String())
+	assert.Equal(t, "72", receipt.Cost.String())
+	assert.Equal(t, "60", receipt.ToBalance.String())
+
+	// blocks persisted prior to receipts get theirs generated again
+	blockFs, err := s.db.GetByHeight(1)
+	require.NoError(t, err)
+	require.Len(t, blockFs.Receipts, 2)
+
+	require.NoError(t, s.db.TruncateFrom(1))
+	require.NoError(t, s.db.Append(BlockFS{Key: blockFs.Key, Value: blockFs.Value}))
+
+	regenerated, err := s.GetReceipt(tx2Hash)
+	require.NoError(t, err)
+	assert.Equal(t, receipt, regenerated)
+}
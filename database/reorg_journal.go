@@ -0,0 +1,100 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"the-blockchain-bar/utils"
+)
+
+// reorgJournal is the side chain branch replacing the main chain blocks from the fork height up.
+//
+// It's persisted before a reorganisation removes any main chain block and deleted once the branch is stored,
+// so a crash in between doesn't leave the main chain shorter than both forks: the swap is finished on the next start.
+type reorgJournal struct {
+	ForkHeight uint64    `json:"fork_height"`
+	Branch     []BlockFS `json:"branch"`
+}
+
+func writeReorgJournal(dataDir string, journal reorgJournal) error {
+	journalJson, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(getReorgJournalFilePath(dataDir), journalJson)
+}
+
+func removeReorgJournal(dataDir string) error {
+	if err := os.Remove(getReorgJournalFilePath(dataDir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// swapMainChain replaces the main chain blocks from the fork height up with the branch, journaled.
+//
+// If storing the branch fails, the replaced blocks are restored. If even that fails,
+// the journal is kept and the branch is stored on the next start by recoverReorgJournal.
+func swapMainChain(dataDir string, db BlockStore, forkHeight uint64, branch []BlockFS, replaced []BlockFS) error {
+	if err := writeReorgJournal(dataDir, reorgJournal{ForkHeight: forkHeight, Branch: branch}); err != nil {
+		return err
+	}
+
+	if err := replaceBlocksFrom(db, forkHeight, branch); err != nil {
+		if restoreErr := replaceBlocksFrom(db, forkHeight, replaced); restoreErr != nil {
+			return fmt.Errorf("storing the side chain failed and so did restoring the main chain, the reorganisation is finished on the next start. %s. %w", restoreErr.Error(), err)
+		}
+
+		if removeErr := removeReorgJournal(dataDir); removeErr != nil {
+			return fmt.Errorf("%s. %w", removeErr.Error(), err)
+		}
+
+		return err
+	}
+
+	return removeReorgJournal(dataDir)
+}
+
+// recoverReorgJournal finishes a reorganisation interrupted by a crash, if any.
+func recoverReorgJournal(dataDir string, db BlockStore) error {
+	path := getReorgJournalFilePath(dataDir)
+	if !utils.FileExist(path) {
+		return nil
+	}
+
+	journalJson, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var journal reorgJournal
+	if err := json.Unmarshal(journalJson, &journal); err != nil {
+		return fmt.Errorf("reorganisation journal '%s' is corrupted. %w", path, err)
+	}
+
+	fmt.Printf("finishing the interrupted reorganisation of the chain from height %d\n", journal.ForkHeight)
+
+	if err := replaceBlocksFrom(db, journal.ForkHeight, journal.Branch); err != nil {
+		return err
+	}
+
+	return removeReorgJournal(dataDir)
+}
+
+// replaceBlocksFrom removes the stored blocks from the height up and appends the given ones, oldest first.
+func replaceBlocksFrom(db BlockStore, height uint64, blocks []BlockFS) error {
+	if err := db.TruncateFrom(height); err != nil {
+		return err
+	}
+
+	for _, blockFs := range blocks {
+		if err := db.Append(blockFs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
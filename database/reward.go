@@ -0,0 +1,90 @@
+package database
+
+import (
+	"math/big"
+)
+
+// BlockReward is the reward of every block on networks whose genesis doesn't set an emission schedule.
+const BlockReward = 100
+
+// RewardPolicy is the emission schedule of new coins, defined by the genesis.
+//
+// The reward of the genesis block is InitialReward, halved every HalvingInterval blocks. Once the genesis balances
+// and all the rewards reach MaxSupply, blocks are rewarded with their fees only. There's no other way to create
+// or destroy coins, so the supply at a block follows from its height alone.
+type RewardPolicy struct {
+	InitialReward   uint
+	HalvingInterval uint64   // blocks between halvings, zero never halves
+	MaxSupply       *big.Int // nil is no cap
+	GenesisSupply   *big.Int // sum of the genesis balances
+}
+
+// Reward returns the new coins paid to the miner of the block at the given height, on top of the fees.
+func (p RewardPolicy) Reward(height uint64) *big.Int {
+	if height == 0 {
+		return new(big.Int).Sub(p.Supply(0), p.GenesisSupply)
+	}
+
+	return new(big.Int).Sub(p.Supply(height), p.Supply(height-1))
+}
+
+// Supply returns the coins in circulation right after the block at the given height was applied.
+func (p RewardPolicy) Supply(height uint64) *big.Int {
+	issued := p.scheduledIssuance(height + 1)
+
+	if p.MaxSupply != nil {
+		available := new(big.Int).Sub(p.MaxSupply, p.GenesisSupply)
+		if available.Sign() < 0 {
+			available.SetInt64(0)
+		}
+
+		if issued.Cmp(available) > 0 {
+			issued = available
+		}
+	}
+
+	return issued.Add(issued, p.GenesisSupply)
+}
+
+// scheduledIssuance sums up the rewards of the first blocks, regardless of the supply cap.
+func (p RewardPolicy) scheduledIssuance(blocks uint64) *big.Int {
+	if p.HalvingInterval == 0 {
+		return new(big.Int).Mul(amount(p.InitialReward), new(big.Int).SetUint64(blocks))
+	}
+
+	issued := big.NewInt(0)
+	reward := p.InitialReward
+
+	for blocks > 0 && reward > 0 {
+		epochBlocks := p.HalvingInterval
+		if blocks < epochBlocks {
+			epochBlocks = blocks
+		}
+
+		issued.Add(issued, new(big.Int).Mul(amount(reward), new(big.Int).SetUint64(epochBlocks)))
+
+		blocks -= epochBlocks
+		reward /= 2
+	}
+
+	return issued
+}
+
+// NextBlockReward returns the new coins paid to the miner of the next block, on top of the fees.
+func (s *State) NextBlockReward() *big.Int {
+	return s.rewardPolicy.Reward(s.NextBlockNumber())
+}
+
+// Supply returns the coins in circulation after the latest block, the genesis balances and all the block rewards.
+func (s *State) Supply() *big.Int {
+	if !s.hasGenesisBlock {
+		return new(big.Int).Set(s.rewardPolicy.GenesisSupply)
+	}
+
+	return s.rewardPolicy.Supply(s.latestBlock.Header.Number)
+}
+
+// RewardPolicy returns the emission schedule of the network.
+func (s *State) RewardPolicy() RewardPolicy {
+	return s.rewardPolicy
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestRewardPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy  RewardPolicy
+		rewards []int64 // of the first blocks
+		supply  int64   // after the last one
+	}{
+		"constant": {
+			policy:  RewardPolicy{InitialReward: 100, GenesisSupply: big.NewInt(1000)},
+			rewards: []int64{100, 100, 100},
+			supply:  1300,
+		},
+		"halving": {
+			policy:  RewardPolicy{InitialReward: 100, HalvingInterval: 2, GenesisSupply: big.NewInt(1000)},
+			rewards: []int64{100, 100, 50, 50, 25, 25, 12, 12, 6},
+			supply:  1380,
+		},
+		"halved down to zero": {
+			policy:  RewardPolicy{InitialReward: 3, HalvingInterval: 1, GenesisSupply: big.NewInt(0)},
+			rewards: []int64{3, 1, 0, 0},
+			supply:  4,
+		},
+		"capped": {
+			policy:  RewardPolicy{InitialReward: 100, MaxSupply: big.NewInt(1250), GenesisSupply: big.NewInt(1000)},
+			rewards: []int64{100, 100, 50, 0, 0},
+			supply:  1250,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for height, reward := range tc.rewards {
+				assert.Equal(t, big.NewInt(reward).String(), tc.policy.Reward(uint64(height)).String(), "reward of block %d", height)
+			}
+
+			assert.Equal(t, big.NewInt(tc.supply).String(), tc.policy.Supply(uint64(len(tc.rewards)-1)).String())
+		})
+	}
+}
+
+func TestState_RewardPolicy(t *testing.T) {
+	blockReward := uint(40)
+	s, key, sender := newTestState(t, Genesis{
+		ForkTIP1:        0,
+		BlockReward:     &blockReward,
+		HalvingInterval: 2,
+		MaxSupply:       big.NewInt(1000090),
+	})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	miner := NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57")
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	assert.Equal(t, "1000000", s.Supply().String())
+
+	// the fees move existing coins to the miner, only the reward is new
+	expectedMinerBalances := []string{"61", "122", "153", "174", "195"}
+	expectedSupplies := []string{"1000040", "1000080", "1000090", "1000090", "1000090"}
+
+	for nonce := uint(1); nonce <= 5; nonce++ {
+		tx := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(1), nonce, ""), key)
+		_, err := s.AddBlock(mineTestBlock(t, s, miner, []SignedTx{tx}))
+		require.NoError(t, err)
+
+		assert.Equal(t, expectedMinerBalances[nonce-1], s.Balance(miner).String())
+		assert.Equal(t, expectedSupplies[nonce-1], s.Supply().String())
+	}
+
+	total := big.NewInt(0)
+	for _, balance := range s.Balances {
+		total.Add(total, balance)
+	}
+	assert.Equal(t, s.Supply().String(), total.String())
+}
+
+func TestGenesis_MaxSupplyCoversBalances(t *testing.T) {
+	genesis := Genesis{
+		Balances:  map[common.Address]*big.Int{NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57"): big.NewInt(1000)},
+		MaxSupply: big.NewInt(999),
+	}
+	assert.Error(t, genesis.validate())
+
+	genesis.MaxSupply = big.NewInt(1000)
+	assert.NoError(t, genesis.validate())
+}
+
+func TestGenesis_ValidatesBalances(t *testing.T) {
+	testCases := map[string]string{
+		"negative": `{"balances":{"0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57":-1}}`,
+		"null":     `{"balances":{"0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57":null}}`,
+	}
+
+	for name, genesisJson := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var genesis Genesis
+			require.NoError(t, json.Unmarshal([]byte(genesisJson), &genesis))
+
+			assert.Error(t, genesis.validate())
+		})
+	}
+
+	var genesis Genesis
+	require.NoError(t, json.Unmarshal([]byte(`{"balances":{"0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57":0}}`), &genesis))
+	assert.NoError(t, genesis.validate())
+}
+
+func TestGenesis_RewardPolicyBlockReward(t *testing.T) {
+	testCases := map[string]struct {
+		genesisJson   string
+		initialReward uint
+	}{
+		"unset":    {`{"fork_tip_1":0}`, BlockReward},
+		"zero":     {`{"fork_tip_1":0,"block_reward":0}`, 0},
+		"non-zero": {`{"fork_tip_1":0,"block_reward":40}`, 40},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var genesis Genesis
+			require.NoError(t, json.Unmarshal([]byte(tc.genesisJson), &genesis))
+
+			assert.Equal(t, tc.initialReward, genesis.rewardPolicy().InitialReward)
+		})
+	}
+}
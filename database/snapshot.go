@@ -0,0 +1,198 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	DefaultSnapshotInterval = 100 // a state snapshot is taken every N blocks
+	snapshotsToKeep         = 3
+	// DefaultHistorySnapshotInterval is how sparse the snapshots kept beyond the newest ones are, a multiple of the snapshot interval
+	DefaultHistorySnapshotInterval = 10 * DefaultSnapshotInterval
+	// DefaultMaxHistoryReplay is the most blocks replayed to rebuild a past state, e.g. the balances at a past block
+	DefaultMaxHistoryReplay = DefaultHistorySnapshotInterval
+	snapshotFilePrefix      = "snapshot-"
+	snapshotFileExt         = ".json"
+)
+
+// Snapshot is the state of all accounts right after the block it references was applied.
+//
+// Loading the newest snapshot on startup avoids replaying, and re-verifying, the whole chain.
+type Snapshot struct {
+	Hash       Hash     `json:"hash"`
+	Number     uint64   `json:"number"`
+	Difficulty uint     `json:"difficulty"`
+	TotalWork  *big.Int `json:"total_work"`
+	// RetargetStartTime is the time of the first block of the difficulty retarget interval, from the TIP-4 fork on
+	RetargetStartTime uint64                      `json:"retarget_start_time,omitempty"`
+	Balances          map[common.Address]*big.Int `json:"balances"`
+	Nonces            map[common.Address]uint     `json:"nonces"`
+}
+
+func newSnapshot(s *State) Snapshot {
+	snapshot := Snapshot{
+		Hash:              s.latestBlockHash,
+		Number:            s.latestBlock.Header.Number,
+		Difficulty:        s.miningDifficulty,
+		TotalWork:         new(big.Int).Set(s.totalWork),
+		RetargetStartTime: s.retargetStartTime,
+		Balances:          make(map[common.Address]*big.Int),
+		Nonces:            make(map[common.Address]uint),
+	}
+
+	for acc, balance := range s.Balances {
+		snapshot.Balances[acc] = balance
+	}
+
+	for acc, nonce := range s.AccountToNonce {
+		snapshot.Nonces[acc] = nonce
+	}
+
+	return snapshot
+}
+
+// writeSnapshot persists the snapshot atomically, it's written to a temporary file first
+// and then renamed, so a crash never leaves a half-written snapshot behind.
+func writeSnapshot(dataDir string, snapshot Snapshot) error {
+	dir := getSnapshotsDirPath(dataDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	snapshotJson, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(getSnapshotFilePath(dataDir, snapshot.Number), snapshotJson)
+}
+
+// listSnapshots returns the heights of all the persisted snapshots, newest first.
+func listSnapshots(dataDir string) ([]uint64, error) {
+	files, err := ioutil.ReadDir(getSnapshotsDirPath(dataDir))
+	if os.IsNotExist(err) {
+		return []uint64{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	heights := make([]uint64, 0)
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, snapshotFilePrefix) || !strings.HasSuffix(name, snapshotFileExt) {
+			continue
+		}
+
+		height, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, snapshotFilePrefix), snapshotFileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		heights = append(heights, height)
+	}
+
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i] > heights[j]
+	})
+
+	return heights, nil
+}
+
+func loadSnapshot(dataDir string, height uint64) (Snapshot, error) {
+	var snapshot Snapshot
+
+	snapshotJson, err := ioutil.ReadFile(getSnapshotFilePath(dataDir, height))
+	if err != nil {
+		return snapshot, err
+	}
+
+	if err := json.Unmarshal(snapshotJson, &snapshot); err != nil {
+		return snapshot, err
+	}
+
+	if snapshot.Number != height {
+		return snapshot, fmt.Errorf("snapshot of block '%d' contains block '%d'", height, snapshot.Number)
+	}
+
+	if snapshot.TotalWork == nil {
+		return snapshot, errors.New("snapshot misses the chain total work")
+	}
+
+	return snapshot, nil
+}
+
+// loadLatestValidSnapshot returns the newest snapshot, up to the given height, referencing a block still present in the chain.
+func loadLatestValidSnapshot(dataDir string, db BlockStore, maxHeight uint64) (Snapshot, bool) {
+	heights, err := listSnapshots(dataDir)
+	if err != nil {
+		fmt.Printf("warning: unable to list state snapshots: %s\n", err)
+
+		return Snapshot{}, false
+	}
+
+	for _, height := range heights {
+		if height > maxHeight {
+			continue
+		}
+
+		snapshot, err := loadSnapshot(dataDir, height)
+		if err != nil {
+			fmt.Printf("warning: ignoring state snapshot of block %d: %s\n", height, err)
+
+			continue
+		}
+
+		blockFs, err := db.GetByHeight(snapshot.Number)
+		if err != nil || blockFs.Key != snapshot.Hash {
+			fmt.Printf("warning: ignoring state snapshot of block %d: block hash doesn't match the chain\n", height)
+
+			continue
+		}
+
+		return snapshot, true
+	}
+
+	return Snapshot{}, false
+}
+
+// pruneSnapshots removes all but the newest snapshotsToKeep snapshots,
+// and those taken every historyInterval blocks to rebuild past states from. A zero interval keeps none of them.
+func pruneSnapshots(dataDir string, historyInterval uint64) error {
+	heights, err := listSnapshots(dataDir)
+	if err != nil {
+		return err
+	}
+
+	for i := snapshotsToKeep; i < len(heights); i++ {
+		if historyInterval != 0 && heights[i]%historyInterval == 0 {
+			continue
+		}
+
+		if err := os.Remove(getSnapshotFilePath(dataDir, heights[i])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getSnapshotsDirPath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "snapshots")
+}
+
+func getSnapshotFilePath(dataDir string, height uint64) string {
+	return filepath.Join(getSnapshotsDirPath(dataDir), fmt.Sprintf("%s%d%s", snapshotFilePrefix, height, snapshotFileExt))
+}
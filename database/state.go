@@ -1,13 +1,16 @@
 package database
 
 import (
-	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"math"
+	"math/big"
 	"sort"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/tsdb/fileutil"
 )
 
 const (
@@ -16,20 +19,73 @@ const (
 	TxGasPriceDefault = 1
 )
 
+var errStopIteration = errors.New("stop iteration")
+
 type State struct {
-	Balances       map[common.Address]uint
+	// Balances values are shared between state copies, so they are replaced on every change, never modified in place.
+	Balances       map[common.Address]*big.Int
 	AccountToNonce map[common.Address]uint
 
-	dbFile *os.File
+	db               BlockStore
+	lock             fileutil.Releaser // of the data dir, released on Close
+	txIndex          *txIndex
+	dataDir          string
+	snapshotInterval uint64
+	historyInterval  uint64 // of the snapshots kept to rebuild past states
+	maxHistoryReplay uint64 // of blocks replayed to rebuild a past state
+	genesisBalances  map[common.Address]*big.Int
+	sideBlocks       map[Hash]Block // blocks of competing branches, not part of the main chain
+	futureBlocks     map[Hash]Block // blocks too far ahead of the local clock, imported once their time comes
+	clock            func() time.Time
 
 	latestBlock      Block
 	latestBlockHash  Hash
 	hasGenesisBlock  bool
 	miningDifficulty uint
+	totalWork        *big.Int // cumulative proof-of-work of the main chain
 	forkTIP1         uint64
+	forkTIP2         uint64
+	forkTIP3         uint64
+	forkTIP4         uint64
+	forkTIP5         uint64
+	forkTIP6         uint64
+	forkTIP7         uint64
+	forkTIP8         uint64
+	forkTIP9         uint64
+	forkTIP10        uint64
+	forkTIP11        uint64
+	chainID          string
+	blockLimits      BlockLimits
+	rewardPolicy     RewardPolicy
+
+	// difficulty retargeting, from the TIP-4 fork on
+	tip4Difficulty    uint
+	targetBlockTime   uint64
+	retargetInterval  uint64
+	retargetStartTime uint64 // time of the first block of the current retarget interval
+
+	recentBlockTimes  []uint64 // time of the last main chain blocks, oldest first
+	maxBlockTimeDrift uint64
 }
 
-func NewStateFromDisk(dataDir string, miningDifficulty uint) (*State, error) {
+// NewStateFromDisk loads the state of the data dir, holding its lock until the state is closed.
+func NewStateFromDisk(dataDir string, miningDifficulty uint) (_ *State, err error) {
+	lock, err := lockDataDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var state *State
+	defer func() {
+		if err != nil {
+			if state != nil && state.db != nil {
+				state.db.Close()
+			}
+
+			lock.Release()
+		}
+	}()
+
 	if err := InitDataDirIfNotExists(dataDir, []byte(genesisJson)); err != nil {
 		return nil, err
 	}
@@ -39,50 +95,64 @@ func NewStateFromDisk(dataDir string, miningDifficulty uint) (*State, error) {
 		return nil, err
 	}
 
-	state := &State{
-		Balances:         map[common.Address]uint{},
-		AccountToNonce:   map[common.Address]uint{},
-		latestBlockHash:  Hash{},
-		latestBlock:      Block{},
-		hasGenesisBlock:  false,
-		miningDifficulty: miningDifficulty,
-		forkTIP1:         genesis.ForkTIP1,
+	maxBlockTimeDrift := genesis.MaxBlockTimeDrift
+	if maxBlockTimeDrift == 0 {
+		maxBlockTimeDrift = DefaultMaxBlockTimeDrift
 	}
 
-	for account, balance := range genesis.Balances {
-		state.Balances[account] = balance
+	state = &State{
+		lock:              lock,
+		Balances:          map[common.Address]*big.Int{},
+		AccountToNonce:    map[common.Address]uint{},
+		latestBlockHash:   Hash{},
+		latestBlock:       Block{},
+		hasGenesisBlock:   false,
+		miningDifficulty:  miningDifficulty,
+		totalWork:         big.NewInt(0),
+		forkTIP1:          genesis.ForkTIP1,
+		forkTIP2:          forkHeight(genesis.ForkTIP2),
+		forkTIP3:          forkHeight(genesis.ForkTIP3),
+		forkTIP4:          forkHeight(genesis.ForkTIP4),
+		forkTIP5:          forkHeight(genesis.ForkTIP5),
+		forkTIP6:          forkHeight(genesis.ForkTIP6),
+		forkTIP7:          forkHeight(genesis.ForkTIP7),
+		forkTIP8:          forkHeight(genesis.ForkTIP8),
+		forkTIP9:          forkHeight(genesis.ForkTIP9),
+		forkTIP10:         forkHeight(genesis.ForkTIP10),
+		forkTIP11:         forkHeight(genesis.ForkTIP11),
+		chainID:           genesis.ChainID,
+		blockLimits:       BlockLimits{MaxTXs: genesis.MaxBlockTXs, MaxSize: genesis.MaxBlockSize},
+		rewardPolicy:      genesis.rewardPolicy(),
+		tip4Difficulty:    genesis.MiningDifficulty,
+		targetBlockTime:   genesis.TargetBlockTime,
+		retargetInterval:  genesis.RetargetInterval,
+		dataDir:           dataDir,
+		snapshotInterval:  DefaultSnapshotInterval,
+		historyInterval:   DefaultHistorySnapshotInterval,
+		maxHistoryReplay:  DefaultMaxHistoryReplay,
+		genesisBalances:   genesis.Balances,
+		sideBlocks:        map[Hash]Block{},
+		futureBlocks:      map[Hash]Block{},
+		clock:             time.Now,
+		maxBlockTimeDrift: maxBlockTimeDrift,
 	}
 
-	dbFilepath := getBlocksDbFilePath(dataDir)
-	state.dbFile, err = os.OpenFile(dbFilepath, os.O_APPEND|os.O_RDWR, 0600)
+	state.db, err = openBlockStore(dataDir, DetectBlockStoreBackend(dataDir))
 	if err != nil {
 		return nil, err
 	}
 
-	scanner := bufio.NewScanner(state.dbFile)
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return nil, err
-		}
-
-		blockFsJson := scanner.Bytes()
-		if len(blockFsJson) == 0 {
-			break
-		}
-
-		var blockFs BlockFS
-
-		if err = json.Unmarshal(blockFsJson, &blockFs); err != nil {
-			return nil, err
-		}
+	if err := recoverReorgJournal(dataDir, state.db); err != nil {
+		return nil, err
+	}
 
-		if err := applyBlock(blockFs.Value, state); err != nil {
-			return nil, err
-		}
+	if err := state.replayMainChain(math.MaxUint64, 0); err != nil {
+		return nil, err
+	}
 
-		state.latestBlockHash = blockFs.Key
-		state.latestBlock = blockFs.Value
-		state.hasGenesisBlock = true
+	state.txIndex, err = openTxIndex(getTxIndexFilePath(dataDir), state.db)
+	if err != nil {
+		return nil, err
 	}
 
 	return state, nil
@@ -115,65 +185,285 @@ func (s *State) AddBlocks(blocks []Block) error {
 }
 
 func (s *State) AddBlock(b Block) (hash Hash, err error) {
-	pendingState := s.copy()
+	update, err := s.ImportBlock(b)
 
-	if err := applyBlock(b, &pendingState); err != nil {
-		return Hash{}, err
-	}
+	return update.Hash, err
+}
 
-	blockHash, err := b.Hash()
+func (s *State) extendMainChain(b Block, blockHash Hash) error {
+	pendingState := s.copy()
+
+	receipts, err := applyBlock(b, &pendingState)
 	if err != nil {
-		return Hash{}, err
+		return err
 	}
 
-	blockFS := BlockFS{blockHash, b}
+	pendingState.setLatestBlock(blockHash, b)
+
+	blockFS := BlockFS{Key: blockHash, Value: b, Receipts: receipts}
 	blockFSJson, err := json.Marshal(blockFS)
 	if err != nil {
-		return Hash{}, err
+		return err
 	}
 
 	fmt.Printf("\npersisting new block to disk:\n")
 	fmt.Printf("\t%s\n", blockFSJson)
 
-	if _, err := s.dbFile.Write(append(blockFSJson, '\n')); err != nil {
-		return Hash{}, err
+	if err := s.db.Append(blockFS); err != nil {
+		return err
 	}
 
-	s.Balances = pendingState.Balances
-	s.AccountToNonce = pendingState.AccountToNonce
-	s.latestBlockHash = blockHash
+	s.indexMainChain(b.Header.Number, []BlockFS{blockFS})
+	s.commit(pendingState)
+	s.snapshotIfDue()
+
+	return nil
+}
+
+// replayMainChain resets the state to genesis and re-applies the main chain blocks below the given height,
+// starting from the newest snapshot still matching the chain, if any. It fails with ErrHistoryUnavailable
+// rather than replaying more than maxBlocks blocks, 0 replays as many as needed.
+func (s *State) replayMainChain(before uint64, maxBlocks uint64) error {
+	s.Balances = make(map[common.Address]*big.Int)
+	s.AccountToNonce = make(map[common.Address]uint)
+	s.latestBlockHash = Hash{}
+	s.latestBlock = Block{}
+	s.hasGenesisBlock = false
+	s.totalWork = big.NewInt(0)
+	s.retargetStartTime = 0
+	s.recentBlockTimes = nil
+
+	for account, balance := range s.genesisBalances {
+		s.Balances[account] = balance
+	}
+
+	if before == 0 {
+		return nil
+	}
+
+	// Resume from the newest snapshot still matching the chain and replay only the blocks after it
+	replayFrom := uint64(0)
+	if snapshot, ok := loadLatestValidSnapshot(s.dataDir, s.db, before-1); ok {
+		if err := s.restoreSnapshot(snapshot); err != nil {
+			return err
+		}
+
+		replayFrom = snapshot.Number + 1
+	}
+
+	if maxBlocks != 0 && before-replayFrom > maxBlocks {
+		return fmt.Errorf("%w prior to block %d, %d blocks would have to be replayed", ErrHistoryUnavailable, before, before-replayFrom)
+	}
+
+	err := s.db.Iterate(replayFrom, func(blockFs BlockFS) error {
+		if blockFs.Value.Header.Number >= before {
+			return errStopIteration
+		}
+
+		if _, err := applyBlock(blockFs.Value, s); err != nil {
+			return err
+		}
+
+		s.setLatestBlock(blockFs.Key, blockFs.Value)
+		s.snapshotIfDue()
+
+		return nil
+	})
+	if err != nil && err != errStopIteration {
+		return err
+	}
+
+	return nil
+}
+
+func (s *State) setLatestBlock(hash Hash, b Block) {
+	s.latestBlockHash = hash
 	s.latestBlock = b
 	s.hasGenesisBlock = true
-	s.miningDifficulty = pendingState.miningDifficulty
+	s.totalWork = new(big.Int).Add(s.totalWork, s.workOf(b))
+	s.trackRetargetInterval(b)
+	s.trackBlockTime(b)
+}
 
-	return blockHash, nil
+// commit replaces the state with a pending one whose blocks are now persisted.
+func (s *State) commit(pendingState State) {
+	s.Balances = pendingState.Balances
+	s.AccountToNonce = pendingState.AccountToNonce
+	s.latestBlockHash = pendingState.latestBlockHash
+	s.latestBlock = pendingState.latestBlock
+	s.hasGenesisBlock = pendingState.hasGenesisBlock
+	s.miningDifficulty = pendingState.miningDifficulty
+	s.totalWork = pendingState.totalWork
+	s.retargetStartTime = pendingState.retargetStartTime
+	s.recentBlockTimes = pendingState.recentBlockTimes
 }
 
 func (s *State) GetNextNonceByAccount(account common.Address) uint {
 	return s.AccountToNonce[account] + 1
 }
 
+// Balance returns the account balance, zero for unknown accounts.
+func (s *State) Balance(account common.Address) *big.Int {
+	if balance, ok := s.Balances[account]; ok {
+		return new(big.Int).Set(balance)
+	}
+
+	return big.NewInt(0)
+}
+
+func (s *State) credit(account common.Address, value *big.Int) {
+	s.Balances[account] = new(big.Int).Add(s.Balance(account), value)
+}
+
 func (s *State) ChangeMiningDifficulty(newDifficulty uint) {
 	s.miningDifficulty = newDifficulty
 }
 
+// TotalWork returns the cumulative proof-of-work of the main chain.
+func (s *State) TotalWork() *big.Int {
+	return new(big.Int).Set(s.totalWork)
+}
+
 func (s *State) IsTIP1Fork() bool {
 	return s.NextBlockNumber() >= s.forkTIP1
 }
 
+// IsTIP2Fork tells whether the next block header must commit to the block TXs through their Merkle root.
+func (s *State) IsTIP2Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP2
+}
+
+// IsTIP3Fork tells whether the next block header must commit to the state resulting from the block.
+func (s *State) IsTIP3Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP3
+}
+
+// IsTIP4Fork tells whether the next block difficulty is a consensus rule recorded in its header.
+func (s *State) IsTIP4Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP4
+}
+
+// IsTIP5Fork tells whether the next block hash must be lower than the target recorded in its header bits.
+func (s *State) IsTIP5Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP5
+}
+
+// IsTIP6Fork tells whether the next block time must be later than the median time of the last blocks.
+func (s *State) IsTIP6Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP6
+}
+
+// IsTIP7Fork tells whether the next block TXs are applied in the block order rather than sorted by time.
+func (s *State) IsTIP7Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP7
+}
+
+// IsTIP8Fork tells whether the next block TXs must be signed for the network chain ID.
+func (s *State) IsTIP8Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP8
+}
+
+// IsTIP9Fork tells whether the next block must fit the genesis block limits.
+func (s *State) IsTIP9Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP9
+}
+
+// IsTIP10Fork tells whether the next block must pay its reward and fees through a coinbase TX.
+func (s *State) IsTIP10Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP10
+}
+
+// IsTIP11Fork tells whether the next block TXs can be sent by multisig accounts.
+func (s *State) IsTIP11Fork() bool {
+	return s.NextBlockNumber() >= s.forkTIP11
+}
+
+// ChainID is the network identifier from the genesis, TXs are signed for from the TIP-8 fork on.
+func (s *State) ChainID() string {
+	return s.chainID
+}
+
+// restoreSnapshot replaces the accounts state with the one recorded in the snapshot.
+func (s *State) restoreSnapshot(snapshot Snapshot) error {
+	blockFs, err := s.db.GetByHeight(snapshot.Number)
+	if err != nil {
+		return err
+	}
+
+	s.Balances = make(map[common.Address]*big.Int)
+	s.AccountToNonce = make(map[common.Address]uint)
+
+	for acc, balance := range snapshot.Balances {
+		s.Balances[acc] = balance
+	}
+
+	for acc, nonce := range snapshot.Nonces {
+		s.AccountToNonce[acc] = nonce
+	}
+
+	// The difficulty the chain was mined at when the snapshot was taken, prior to TIP-4 the configured one
+	// is only the default of a fresh chain. From the fork on it's recorded in the block headers and retargeted
+	// from the current interval start.
+	if snapshot.Difficulty != 0 {
+		s.miningDifficulty = snapshot.Difficulty
+	}
+
+	s.totalWork = new(big.Int).Set(snapshot.TotalWork)
+	s.retargetStartTime = snapshot.RetargetStartTime
+
+	if err := s.loadRecentBlockTimes(snapshot.Number); err != nil {
+		return err
+	}
+	s.latestBlockHash = blockFs.Key
+	s.latestBlock = blockFs.Value
+	s.hasGenesisBlock = true
+
+	return nil
+}
+
+// snapshotIfDue persists a snapshot of the state every snapshotInterval blocks.
+// Snapshots are an optimization, so failing to write one doesn't fail the block.
+func (s *State) snapshotIfDue() {
+	number := s.latestBlock.Header.Number
+	if s.snapshotInterval == 0 || number == 0 || number%s.snapshotInterval != 0 {
+		return
+	}
+
+	if err := writeSnapshot(s.dataDir, newSnapshot(s)); err != nil {
+		fmt.Printf("warning: unable to write state snapshot of block %d: %s\n", number, err)
+
+		return
+	}
+
+	if err := pruneSnapshots(s.dataDir, s.historyInterval); err != nil {
+		fmt.Printf("warning: unable to prune old state snapshots: %s\n", err)
+	}
+}
+
 func (s *State) Close() error {
-	return s.dbFile.Close()
+	if s.txIndex != nil {
+		if err := s.txIndex.close(); err != nil {
+			s.db.Close()
+			s.lock.Release()
+
+			return err
+		}
+	}
+
+	if err := s.db.Close(); err != nil {
+		s.lock.Release()
+
+		return err
+	}
+
+	return s.lock.Release()
 }
 
 func (s *State) copy() State {
-	c := State{}
-	c.latestBlock = s.latestBlock
-	c.latestBlockHash = s.latestBlockHash
-	c.hasGenesisBlock = s.hasGenesisBlock
-	c.Balances = make(map[common.Address]uint)
+	c := *s
+	c.Balances = make(map[common.Address]*big.Int)
 	c.AccountToNonce = make(map[common.Address]uint)
-	c.miningDifficulty = s.miningDifficulty
-	c.forkTIP1 = s.forkTIP1
+	c.totalWork = new(big.Int).Set(s.totalWork)
 
 	for acc, balance := range s.Balances {
 		c.Balances[acc] = balance
@@ -188,107 +478,311 @@ func (s *State) copy() State {
 
 // applyBlock verifies if block can be added to the blockchain.
 // Block metadata are verified as well as transactions within (sufficient balances, etc).
-func applyBlock(b Block, s *State) error {
+// It returns the receipts of the block TXs, in the block order.
+func applyBlock(b Block, s *State) ([]Receipt, error) {
 	nextExpectedBlockNumber := s.latestBlock.Header.Number + 1
 
 	if s.hasGenesisBlock && b.Header.Number != nextExpectedBlockNumber {
-		return fmt.Errorf("next expected block must '%d' not '%d'", nextExpectedBlockNumber, b.Header.Number)
+		return nil, newBlockMismatchError(b.Header.Number, ErrBadBlockNumber, nextExpectedBlockNumber, b.Header.Number, "next expected block must '%d' not '%d'", nextExpectedBlockNumber, b.Header.Number)
 	}
 
 	if s.hasGenesisBlock && s.latestBlock.Header.Number > 0 && s.latestBlockHash.Hex() != b.Header.Parent.Hex() {
-		return fmt.Errorf("next block parent hash must be '%x' not '%x'", s.latestBlockHash, b.Header.Parent)
+		return nil, newBlockMismatchError(b.Header.Number, ErrBadParent, s.latestBlockHash, b.Header.Parent, "next block parent hash must be '%x' not '%x'", s.latestBlockHash, b.Header.Parent)
 	}
 
 	hash, err := b.Hash()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if !IsBlockHashValid(hash, s.miningDifficulty) {
-		return fmt.Errorf("invalid block hash %x", hash)
+	if s.IsTIP5Fork() {
+		if err := b.validateBits(s.NextBlockBits()); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := b.validateDifficulty(s.NextBlockDifficulty(), s.IsTIP4Fork()); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := applyTXs(b.TXs, s); err != nil {
-		return err
+	if !s.isBlockHashValid(hash, b) {
+		return nil, newBlockError(b.Header.Number, ErrBadPoW, "invalid block hash %x", hash)
 	}
 
-	s.Balances[b.Header.Miner] += BlockReward
+	if err := b.validateTime(s.MedianTimePast(), s.IsTIP6Fork()); err != nil {
+		return nil, err
+	}
 
-	if s.IsTIP1Fork() {
-		s.Balances[b.Header.Miner] += b.GasReward()
-	} else {
-		s.Balances[b.Header.Miner] += uint(len(b.TXs)) * TxFee
+	if err := b.validateTxRoot(s.IsTIP2Fork()); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := b.validateLimits(s.NextBlockLimits()); err != nil {
+		return nil, err
+	}
+
+	isTIP3Fork := s.IsTIP3Fork()
+
+	receipts, err := applyBlockTXs(b, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.validateStateRoot(s.StateRoot(), isTIP3Fork); err != nil {
+		return nil, err
+	}
+
+	for i := range receipts {
+		receipts[i].BlockHash = hash
+	}
+
+	return receipts, nil
 }
 
-func applyTXs(txs []SignedTx, s *State) error {
-	// sort TXs by time before applying them
-	sort.Slice(txs, func(i, j int) bool {
-		return txs[i].Time < txs[j].Time
-	})
+// applyBlockTXs applies the block TXs and rewards its miner, without verifying the block metadata.
+func applyBlockTXs(b Block, s *State) ([]Receipt, error) {
+	isTIP10Fork := s.IsTIP10Fork()
+
+	txs := b.TXs
+	if isTIP10Fork {
+		if err := b.validateCoinbase(s.NextBlockReward(), s.IsTIP1Fork()); err != nil {
+			return nil, err
+		}
+
+		txs = b.TXs[1:]
+	}
+
+	applied, err := applyTXs(txs, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// the coinbase is applied last, so the miner can't spend the reward in its own block
+	if isTIP10Fork {
+		receipt, err := applyCoinbase(b.TXs[0], s)
+		if err != nil {
+			return nil, err
+		}
+
+		applied = append(applied, receipt)
+	}
+
+	// the TXs may be applied in another order than the block one, prior to TIP-7
+	byHash := make(map[Hash]Receipt)
+	for _, receipt := range applied {
+		byHash[receipt.TxHash] = receipt
+	}
+
+	receipts := make([]Receipt, len(b.TXs))
+	for i, tx := range b.TXs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		receipts[i] = byHash[txHash]
+		receipts[i].BlockNumber = b.Header.Number
+		receipts[i].Index = uint(i)
+	}
+
+	// prior to TIP-10, the reward and fees are credited to the miner without a TX
+	if !isTIP10Fork {
+		s.credit(b.Header.Miner, new(big.Int).Add(s.NextBlockReward(), blockFees(b.TXs, s.IsTIP1Fork())))
+	}
+
+	return receipts, nil
+}
 
+func applyTXs(txs []SignedTx, s *State) ([]Receipt, error) {
+	if s.IsTIP7Fork() {
+		if err := validateTxOrder(txs); err != nil {
+			return nil, err
+		}
+	} else {
+		txs = sortTXsByTime(txs)
+	}
+
+	receipts := make([]Receipt, 0, len(txs))
 	for _, tx := range txs {
-		err := applyTx(tx, s)
+		receipt, err := applyTx(tx, s)
 		if err != nil {
-			return err
+			return nil, err
 		}
+
+		receipts = append(receipts, receipt)
 	}
 
-	return nil
+	return receipts, nil
 }
 
-func applyTx(tx SignedTx, s *State) error {
+func applyTx(tx SignedTx, s *State) (Receipt, error) {
 	if err := validateTx(tx, s); err != nil {
-		return err
+		return Receipt{}, err
 	}
 
-	s.Balances[tx.From] -= tx.Cost(s.IsTIP1Fork())
-	s.Balances[tx.To] += tx.Value
+	s.Balances[tx.From] = new(big.Int).Sub(s.Balance(tx.From), tx.Cost(s.IsTIP1Fork()))
+	s.credit(tx.To, amountOrZero(tx.Value))
 
 	s.AccountToNonce[tx.From] = tx.Nonce
 
-	return nil
+	return newReceipt(tx, s)
+}
+
+// ValidateTx checks the TX could be applied on top of the current state.
+func (s *State) ValidateTx(tx SignedTx) error {
+	return validateTx(tx, s)
+}
+
+// ValidatePendingTx checks the TX could be applied right after the pending TXs of its sender, e.g. before adding it
+// to the pending TXs, so a client can queue several TXs before the first one is mined.
+//
+// The nonce must follow the sender's last pending TX and the balance cover the cost of all of them, see afterPendingTXs.
+func (s *State) ValidatePendingTx(tx SignedTx, pendingTXs []SignedTx) error {
+	txHash, err := tx.Hash()
+	if err != nil {
+		return err
+	}
+
+	nonce, balance, err := s.afterPendingTXs(tx.From, pendingTXs, txHash)
+	if err != nil {
+		return err
+	}
+
+	return validateTxAt(tx, s, nonce, balance)
+}
+
+// GetNextNonceAfterPending returns the account's next nonce following its pending TXs.
+func (s *State) GetNextNonceAfterPending(account common.Address, pendingTXs []SignedTx) (uint, error) {
+	nonce, _, err := s.afterPendingTXs(account, pendingTXs, Hash{})
+
+	return nonce, err
+}
+
+// afterPendingTXs returns the account's next nonce and balance once its pending TXs are applied, but the excluded one.
+//
+// Only the TXs following each other from the account's next nonce are counted in, the others couldn't be mined next.
+func (s *State) afterPendingTXs(account common.Address, pendingTXs []SignedTx, excluded Hash) (uint, *big.Int, error) {
+	accountTXs := make([]SignedTx, 0)
+	for _, tx := range pendingTXs {
+		if tx.From != account {
+			continue
+		}
+
+		txHash, err := tx.Hash()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if txHash != excluded {
+			accountTXs = append(accountTXs, tx)
+		}
+	}
+
+	sort.Slice(accountTXs, func(i, j int) bool {
+		return accountTXs[i].Nonce < accountTXs[j].Nonce
+	})
+
+	nonce := s.GetNextNonceByAccount(account)
+	balance := new(big.Int).Set(s.Balance(account))
+	for _, tx := range accountTXs {
+		if tx.Nonce < nonce {
+			continue
+		}
+
+		if tx.Nonce > nonce {
+			break
+		}
+
+		nonce++
+		balance.Sub(balance, tx.Cost(s.IsTIP1Fork()))
+	}
+
+	return nonce, balance, nil
 }
 
 func validateTx(tx SignedTx, s *State) error {
+	return validateTxAt(tx, s, s.GetNextNonceByAccount(tx.From), s.Balance(tx.From))
+}
+
+// validateTxAt checks the TX could be applied with the sender's next nonce and balance.
+func validateTxAt(tx SignedTx, s *State, expectedNonce uint, balance *big.Int) error {
+	if tx.IsMultisig() {
+		// Nodes prior to TIP11 drop the multisig when decoding a TX, they couldn't verify its signatures
+		if !s.IsTIP11Fork() {
+			return fmt.Errorf("%w. `Multisig` can't be populated before TIP11 fork is active", ErrInvalidMultisig)
+		}
+
+		if len(tx.Sig) != 0 {
+			return fmt.Errorf("%w. a multisig TX is signed by its owners only, its `Sig` must be empty", ErrInvalidMultisig)
+		}
+	}
+
 	validTx, err := tx.IsAuthentic()
-	if err != nil {
+	if errors.Is(err, ErrInvalidMultisig) {
 		return err
 	}
 
+	if err != nil {
+		return &ForgedSignatureError{Account: tx.From, Err: err}
+	}
+
 	if !validTx {
-		return fmt.Errorf("wrong tx. sender '%s' is forged", tx.From.String())
+		return &ForgedSignatureError{Account: tx.From}
+	}
+
+	if tx.hasNegativeAmount() {
+		return fmt.Errorf("%w. value %s, gas %s and gas price %s can't be negative", ErrInvalidValue, amountOrZero(tx.Value), amountOrZero(tx.Gas), amountOrZero(tx.GasPrice))
 	}
 
-	expectedNonce := s.GetNextNonceByAccount(tx.From)
 	if tx.Nonce != expectedNonce {
-		return fmt.Errorf("wrong tx. sender '%s' next nonce must be '%d', not '%d'", tx.From.String(), expectedNonce, tx.Nonce)
+		return &InvalidNonceError{Account: tx.From, Expected: expectedNonce, Actual: tx.Nonce}
 	}
 
 	if s.IsTIP1Fork() {
 		// Now we only have one action type, tx `transfer`, so all TXs must pay 21 gas like on Ethereum (21 000)
-		if tx.Gas != TxGas {
-			return fmt.Errorf("insufficient TX gas %v. required: %v", tx.Gas, TxGas)
+		if amountOrZero(tx.Gas).Cmp(amount(TxGas)) != 0 {
+			return fmt.Errorf("%w. insufficient gas %v. required: %v", ErrInvalidGas, amountOrZero(tx.Gas), TxGas)
 		}
 
-		if tx.GasPrice < TxGasPriceDefault {
-			return fmt.Errorf("insufficient TX gasPrice %v. required at least: %v", tx.GasPrice, TxGasPriceDefault)
+		if amountOrZero(tx.GasPrice).Cmp(amount(TxGasPriceDefault)) < 0 {
+			return fmt.Errorf("%w. insufficient gasPrice %v. required at least: %v", ErrInvalidGas, amountOrZero(tx.GasPrice), TxGasPriceDefault)
 		}
 
 	} else {
 		// Prior to TIP1, a signed TX must NOT populate the Gas fields to prevent consensus from crashing
 		// It's not enough to add this validation to http handlers because a TX could come from another node
 		// that could modify its software and broadcast such a TX, it must be validated here too.
-		if tx.Gas != 0 || tx.GasPrice != 0 {
-			return fmt.Errorf("invalid TX. `Gas` and `GasPrice` can't be populate before TIP1 fork is active")
+		if amountOrZero(tx.Gas).Sign() != 0 || amountOrZero(tx.GasPrice).Sign() != 0 {
+			return fmt.Errorf("%w. `Gas` and `GasPrice` can't be populate before TIP1 fork is active", ErrInvalidGas)
+		}
+	}
+
+	if s.IsTIP8Fork() {
+		if tx.ChainID != s.chainID {
+			return fmt.Errorf("%w. must be '%s', not '%s'", ErrInvalidChainID, s.chainID, tx.ChainID)
 		}
+	} else if tx.ChainID != "" {
+		// Nodes prior to TIP8 drop the chain ID when decoding a TX, they couldn't verify its signature
+		return fmt.Errorf("%w. `ChainID` can't be populated before TIP8 fork is active", ErrInvalidChainID)
 	}
 
-	if tx.Cost(s.IsTIP1Fork()) > s.Balances[tx.From] {
-		return fmt.Errorf("wrong tx. sender '%s' balance is %d TBB. tx cost is %d TBB", tx.From.String(), s.Balances[tx.From], tx.Cost(s.IsTIP1Fork()))
+	if tx.Cost(s.IsTIP1Fork()).Cmp(balance) > 0 {
+		return &InsufficientBalanceError{Account: tx.From, Balance: balance, Cost: tx.Cost(s.IsTIP1Fork())}
 	}
 
 	return nil
 }
+
+// amount converts a reward or fee to the big integer balances are kept in.
+func amount(value uint) *big.Int {
+	return new(big.Int).SetUint64(uint64(value))
+}
+
+// amountOrZero returns the TX amount, zero when it's unset.
+func amountOrZero(value *big.Int) *big.Int {
+	if value == nil {
+		return big.NewInt(0)
+	}
+
+	return value
+}
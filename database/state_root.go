@@ -0,0 +1,66 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StateRoot returns the root of a Merkle tree over the accounts state, committed to in block headers from the TIP-3 fork on.
+//
+// The leaves are the accounts sorted by address, each hashing the address, balance and nonce.
+// Accounts without balance and nonce are left out, so merely looking an account up doesn't change the root.
+func (s *State) StateRoot() Hash {
+	accounts := make([]common.Address, 0, len(s.Balances))
+	for account := range s.Balances {
+		if s.Balances[account].Sign() != 0 || s.AccountToNonce[account] != 0 {
+			accounts = append(accounts, account)
+		}
+	}
+
+	for account, nonce := range s.AccountToNonce {
+		if _, ok := s.Balances[account]; !ok && nonce != 0 {
+			accounts = append(accounts, account)
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i][:], accounts[j][:]) < 0
+	})
+
+	leaves := make([]Hash, len(accounts))
+	for i, account := range accounts {
+		leaves[i] = accountLeafHash(account, s.Balance(account), s.AccountToNonce[account])
+	}
+
+	levels := merkleLevels(leaves)
+
+	return levels[len(levels)-1][0]
+}
+
+// NextStateRoot returns the state root after a block with the given TXs would be applied, for miners to commit to.
+func (s *State) NextStateRoot(miner common.Address, txs []SignedTx) (Hash, error) {
+	pendingState := s.copy()
+
+	b := NewBlock(s.latestBlockHash, s.NextBlockNumber(), 0, 0, miner, txs)
+	if _, err := applyBlockTXs(b, &pendingState); err != nil {
+		return Hash{}, err
+	}
+
+	return pendingState.StateRoot(), nil
+}
+
+// accountLeafHash hashes the account with its balance as a 256-bit and its nonce as a 64-bit big endian number.
+func accountLeafHash(account common.Address, balance *big.Int, nonce uint) Hash {
+	data := make([]byte, 1+common.AddressLength+32+8)
+	data[0] = merkleLeafPrefix
+	copy(data[1:], account[:])
+	balance.FillBytes(data[1+common.AddressLength : 1+common.AddressLength+32])
+	binary.BigEndian.PutUint64(data[1+common.AddressLength+32:], uint64(nonce))
+
+	return sha256.Sum256(data)
+}
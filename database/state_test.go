@@ -0,0 +1,220 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"testing"
+	"the-blockchain-bar/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+const testMiningDifficulty = 1
+
+func TestState_Snapshots(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+
+	s.snapshotInterval = 2
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	for nonce := uint(1); nonce <= 5; nonce++ {
+		tx := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), nonce, ""), key)
+		_, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx}))
+		require.NoError(t, err)
+	}
+
+	expectedBalances := s.Balances
+	dataDir := s.dataDir
+	require.NoError(t, s.Close())
+
+	heights, err := listSnapshots(dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{4, 2}, heights)
+
+	// Restarting from the newest snapshot gives the same state as the full replay,
+	// mined at the chain difficulty rather than the configured default
+	restarted, err := NewStateFromDisk(dataDir, testMiningDifficulty+1)
+	require.NoError(t, err)
+	assertBalances(t, expectedBalances, restarted.Balances)
+	assert.Equal(t, uint64(4), restarted.LatestBlock().Header.Number)
+	assert.Equal(t, uint(testMiningDifficulty), restarted.NextBlockDifficulty())
+	require.NoError(t, restarted.Close())
+
+	// Make the newest snapshot reference a block not in the chain, the older one must be picked up instead.
+	// Its balances are tampered with to prove the replay started from it.
+	snapshot, err := loadSnapshot(dataDir, 4)
+	require.NoError(t, err)
+	snapshot.Hash = Hash{}
+	require.NoError(t, writeSnapshot(dataDir, snapshot))
+
+	snapshot, err = loadSnapshot(dataDir, 2)
+	require.NoError(t, err)
+	snapshot.Balances[receiver] = new(big.Int).Add(snapshot.Balances[receiver], big.NewInt(1000))
+	require.NoError(t, writeSnapshot(dataDir, snapshot))
+
+	restarted, err = NewStateFromDisk(dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	assert.Equal(t, new(big.Int).Add(expectedBalances[receiver], big.NewInt(1000)).String(), restarted.Balance(receiver).String())
+	require.NoError(t, restarted.Close())
+}
+
+func TestState_LocksDataDir(t *testing.T) {
+	s, _, _ := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+
+	// e.g. a CLI command while the node is running
+	_, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	assert.True(t, errors.Is(err, ErrDataDirLocked))
+	assert.True(t, errors.Is(MigrateBlockStore(s.dataDir, BackendLevelDB), ErrDataDirLocked))
+
+	require.NoError(t, s.Close())
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	require.NoError(t, restarted.Close())
+}
+
+func TestState_RejectsOverflowingTxCost(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	// 21 gas at this price wraps around 64 bits into a cost of a few TBB
+	gasPrice := uint(math.MaxUint64/TxGas + 1)
+	tx := signTestTx(t, NewTx(sender, receiver, big.NewInt(10), 1, amount(TxGas), amount(gasPrice), ""), key)
+
+	expectedCost, _ := new(big.Int).SetString("18446744073709551631", 10)
+	assert.Equal(t, expectedCost.String(), tx.Cost(true).String())
+
+	pendingState := s.copy()
+	_, err := applyTx(tx, &pendingState)
+	assert.Error(t, err)
+	assert.Equal(t, big.NewInt(1000000).String(), pendingState.Balance(sender).String())
+	assert.Equal(t, big.NewInt(0).String(), pendingState.Balance(receiver).String())
+}
+
+func TestState_BalancesJsonCompatibility(t *testing.T) {
+	account := NewAccount("0x09eE50f2F37FcBA1845dE6FE5C762E83E65E755c")
+
+	var genesis Genesis
+	require.NoError(t, json.Unmarshal([]byte(`{"balances": {"0x09eE50f2F37FcBA1845dE6FE5C762E83E65E755c": 1000000}}`), &genesis))
+	assert.Equal(t, big.NewInt(1000000).String(), genesis.Balances[account].String())
+
+	// Balances beyond 64 bits are still written as plain JSON numbers
+	balance, _ := new(big.Int).SetString("340282366920938463463374607431768211456", 10)
+	snapshotJson, err := json.Marshal(Snapshot{Balances: map[common.Address]*big.Int{account: balance}})
+	require.NoError(t, err)
+	assert.Contains(t, string(snapshotJson), `"0x09ee50f2f37fcba1845de6fe5c762e83e65e755c":340282366920938463463374607431768211456`)
+
+	var snapshot Snapshot
+	require.NoError(t, json.Unmarshal(snapshotJson, &snapshot))
+	assert.Equal(t, balance.String(), snapshot.Balances[account].String())
+}
+
+// newTestState initializes a temporary data dir with the genesis and a funded account able to sign TXs.
+// Remember to remove the dir once test finishes: defer utils.RemoveDir(s.dataDir)
+func newTestState(t *testing.T, genesis Genesis) (*State, *ecdsa.PrivateKey, common.Address) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_state_test")
+	require.NoError(t, err)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	if genesis.Balances == nil {
+		genesis.Balances = map[common.Address]*big.Int{account: big.NewInt(1000000)}
+	}
+
+	genesisJson, err := json.Marshal(genesis)
+	require.NoError(t, err)
+	require.NoError(t, InitDataDirIfNotExists(dataDir, genesisJson))
+
+	s, err := NewStateFromDisk(dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+
+	return s, key, account
+}
+
+// assertBalances compares balances by value, big.Int internals differ between equal numbers.
+func assertBalances(t *testing.T, expected map[common.Address]*big.Int, actual map[common.Address]*big.Int) {
+	t.Helper()
+
+	require.Equal(t, len(expected), len(actual))
+	for account, balance := range expected {
+		require.Contains(t, actual, account)
+		assert.Equal(t, balance.String(), actual[account].String(), account.String())
+	}
+}
+
+func signTestTx(t *testing.T, tx Tx, key *ecdsa.PrivateKey) SignedTx {
+	txJson, err := tx.Encode()
+	require.NoError(t, err)
+
+	txHash := sha256.Sum256(txJson)
+	sig, err := crypto.Sign(txHash[:], key)
+	require.NoError(t, err)
+
+	return NewSignedTx(tx, sig)
+}
+
+// mineTestBlock creates the next valid block on top of the state by brute-forcing its nonce.
+func mineTestBlock(t *testing.T, s *State, miner common.Address, txs []SignedTx) Block {
+	return mineTestBlockOn(t, s, s.LatestBlockHash(), s.NextBlockNumber(), miner, txs)
+}
+
+// mineTestBlockOn creates a valid block on top of any parent, e.g. to build a side chain.
+func mineTestBlockOn(t *testing.T, s *State, parent Hash, number uint64, miner common.Address, txs []SignedTx) Block {
+	if number >= s.forkTIP10 && parent == s.LatestBlockHash() {
+		coinbase, err := s.NewCoinbaseTx(miner, txs)
+		require.NoError(t, err)
+		txs = append([]SignedTx{coinbase}, txs...)
+	}
+
+	block := NewBlock(parent, number, 0, uint64(time.Now().Unix()), miner, txs)
+	if number >= s.forkTIP2 {
+		require.NoError(t, block.CommitTXs())
+	}
+
+	// the state root and difficulty can only be known for blocks on top of the main chain
+	if number >= s.forkTIP3 && parent == s.LatestBlockHash() {
+		stateRoot, err := s.NextStateRoot(miner, txs)
+		require.NoError(t, err)
+		block.Header.StateRoot = &stateRoot
+	}
+
+	if number >= s.forkTIP5 && parent == s.LatestBlockHash() {
+		block.Header.Bits = s.NextBlockBits()
+	} else if number >= s.forkTIP4 && parent == s.LatestBlockHash() {
+		block.Header.Difficulty = s.NextBlockDifficulty()
+	}
+
+	return mineTestBlockWithHeader(t, s, block)
+}
+
+// mineTestBlockWithHeader brute-forces the nonce of the block, e.g. after its header was modified,
+// so only the modification makes it invalid.
+func mineTestBlockWithHeader(t *testing.T, s *State, b Block) Block {
+	for nonce := uint32(0); ; nonce++ {
+		b.Header.Nonce = nonce
+
+		hash, err := b.Hash()
+		require.NoError(t, err)
+
+		if s.isBlockHashValid(hash, b) {
+			return b
+		}
+	}
+}
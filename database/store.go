@@ -0,0 +1,180 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"the-blockchain-bar/utils"
+)
+
+const (
+	BackendFile    = "file"
+	BackendLevelDB = "leveldb"
+)
+
+// BlockStore persists the blockchain blocks in order of their height.
+type BlockStore interface {
+	// Append persists the next block on top of the current tip.
+	Append(blockFs BlockFS) error
+	GetByHash(hash Hash) (BlockFS, error)
+	GetByHeight(height uint64) (BlockFS, error)
+	// Iterate calls fn for every block from the given height up to the tip, stopping on the first error.
+	Iterate(fromHeight uint64, fn func(blockFs BlockFS) error) error
+	// Tip returns the latest persisted block or ErrBlockNotFound if the store is empty.
+	Tip() (BlockFS, error)
+	// TruncateFrom removes every block from the given height up, e.g. when the chain reorganises.
+	TruncateFrom(height uint64) error
+	Close() error
+}
+
+// ConfigureBlockStore makes sure the data dir stores its blocks using the given backend.
+//
+// A new data dir is initialized with the backend, while an existing one must already use it,
+// otherwise it has to be converted with MigrateBlockStore first.
+// An empty backend keeps whatever the data dir uses, BackendFile for new data dirs.
+func ConfigureBlockStore(dataDir string, backend string) error {
+	lock, err := lockDataDir(dataDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if backend == "" {
+		return InitDataDirIfNotExists(dataDir, []byte(genesisJson))
+	}
+
+	if err := validateBackend(backend); err != nil {
+		return err
+	}
+
+	if !utils.FileExist(getGenesisJsonFilePath(dataDir)) {
+		return initDataDir(dataDir, []byte(genesisJson), backend)
+	}
+
+	if current := DetectBlockStoreBackend(dataDir); current != backend {
+		return fmt.Errorf("data dir stores blocks in the '%s' backend, not '%s'. migrate it first", current, backend)
+	}
+
+	return nil
+}
+
+// DetectBlockStoreBackend tells which backend the data dir stores its blocks in.
+func DetectBlockStoreBackend(dataDir string) string {
+	if utils.FileExist(getBlocksLevelDBDirPath(dataDir)) {
+		return BackendLevelDB
+	}
+
+	return BackendFile
+}
+
+// MigrateBlockStore copies all the blocks of the data dir into a new store of the given backend.
+//
+// The new store is built aside and only swapped in once complete. The previous one is kept
+// with a ".bak" suffix, so an interrupted migration leaves the data dir untouched.
+func MigrateBlockStore(dataDir string, to string) error {
+	if err := validateBackend(to); err != nil {
+		return err
+	}
+
+	lock, err := lockDataDir(dataDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if err := MigrateDataDir(dataDir); err != nil {
+		return err
+	}
+
+	from := DetectBlockStoreBackend(dataDir)
+	if from == to {
+		return fmt.Errorf("data dir already stores blocks in the '%s' backend", to)
+	}
+
+	src, err := openBlockStore(dataDir, from)
+	if err != nil {
+		return err
+	}
+
+	srcPath, dstPath := getBlockStorePath(dataDir, from), getBlockStorePath(dataDir, to)
+	tmpPath := strings.TrimSuffix(dstPath, filepath.Ext(dstPath)) + ".migrating" + filepath.Ext(dstPath)
+	if err := utils.RemoveDir(tmpPath); err != nil {
+		return err
+	}
+
+	dst, err := newBlockStoreAt(tmpPath, to)
+	if err != nil {
+		src.Close()
+
+		return err
+	}
+
+	err = src.Iterate(0, func(blockFs BlockFS) error {
+		return dst.Append(blockFs)
+	})
+
+	src.Close()
+	dst.Close()
+
+	if err != nil {
+		return err
+	}
+
+	// the file store indexes are derived from block.db and get rebuilt on the next open
+	if err := os.RemoveAll(getBlocksIndexFilePath(srcPath)); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(getBlocksIndexFilePath(tmpPath)); err != nil {
+		return err
+	}
+
+	if err := utils.RemoveDir(srcPath + ".bak"); err != nil {
+		return err
+	}
+
+	if err := os.Rename(srcPath, srcPath+".bak"); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, dstPath)
+}
+
+func openBlockStore(dataDir string, backend string) (BlockStore, error) {
+	switch backend {
+	case BackendLevelDB:
+		return openLevelDBBlockStore(getBlocksLevelDBDirPath(dataDir))
+	default:
+		return openFileBlockStore(getBlocksDbFilePath(dataDir))
+	}
+}
+
+func newBlockStoreAt(path string, backend string) (BlockStore, error) {
+	switch backend {
+	case BackendLevelDB:
+		return openLevelDBBlockStore(path)
+	default:
+		if err := writeEmptyBlocksDbToDisk(path); err != nil {
+			return nil, err
+		}
+
+		return openFileBlockStore(path)
+	}
+}
+
+func getBlockStorePath(dataDir string, backend string) string {
+	if backend == BackendLevelDB {
+		return getBlocksLevelDBDirPath(dataDir)
+	}
+
+	return getBlocksDbFilePath(dataDir)
+}
+
+func validateBackend(backend string) error {
+	if backend != BackendFile && backend != BackendLevelDB {
+		return fmt.Errorf("unknown blocks backend '%s'. supported: '%s', '%s'", backend, BackendFile, BackendLevelDB)
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+)
+
+const recordChecksumLength = 8
+
+// fileBlockStore keeps the blocks in a JSON-lines file, one BlockFS per line, located through a blockIndex.
+//
+// Every record is prefixed with the CRC32 checksum of its JSON and fsynced once written:
+//
+//	<8 hex chars checksum> <BlockFS JSON>\n
+//
+// Records written by older versions, without the checksum, are still readable.
+// A record left without its new line by a crash in the middle of a write is cut off when the store is opened.
+type fileBlockStore struct {
+	file  *os.File
+	index *blockIndex
+}
+
+func openFileBlockStore(path string) (*fileBlockStore, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recoverTornRecord(f); err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	index, err := openBlockIndex(getBlocksIndexFilePath(path), f)
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	return &fileBlockStore{file: f, index: index}, nil
+}
+
+func (fs *fileBlockStore) Append(blockFs BlockFS) error {
+	record, err := encodeBlockRecord(blockFs)
+	if err != nil {
+		return err
+	}
+
+	// checked first, so block.db never gets a record the index rejects
+	entry, err := fs.index.nextEntry(blockFs.Key, blockFs.Value.Header.Number, int64(len(record)))
+	if err != nil {
+		return err
+	}
+
+	if _, err := fs.file.Write(record); err != nil {
+		return err
+	}
+
+	if err := fs.file.Sync(); err != nil {
+		return err
+	}
+
+	return fs.index.append(entry)
+}
+
+func (fs *fileBlockStore) GetByHash(hash Hash) (BlockFS, error) {
+	entry, ok := fs.index.entryByHash(hash)
+	if !ok {
+		return BlockFS{}, ErrBlockNotFound
+	}
+
+	return fs.read(entry)
+}
+
+func (fs *fileBlockStore) GetByHeight(height uint64) (BlockFS, error) {
+	entry, ok := fs.index.entryByHeight(height)
+	if !ok {
+		return BlockFS{}, ErrBlockNotFound
+	}
+
+	return fs.read(entry)
+}
+
+func (fs *fileBlockStore) Iterate(fromHeight uint64, fn func(blockFs BlockFS) error) error {
+	from, ok := fs.index.entryByHeight(fromHeight)
+	if !ok {
+		return nil
+	}
+
+	// read the records sequentially, starting from the first requested block
+	reader := bufio.NewReader(io.NewSectionReader(fs.file, from.Offset, fs.index.dbSize-from.Offset))
+	for {
+		record, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		blockFs, err := decodeBlockRecord(record)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(blockFs); err != nil {
+			return err
+		}
+	}
+}
+
+func (fs *fileBlockStore) Tip() (BlockFS, error) {
+	if len(fs.index.byHeight) == 0 {
+		return BlockFS{}, ErrBlockNotFound
+	}
+
+	return fs.GetByHeight(uint64(len(fs.index.byHeight) - 1))
+}
+
+func (fs *fileBlockStore) TruncateFrom(height uint64) error {
+	entry, ok := fs.index.entryByHeight(height)
+	if !ok {
+		return nil
+	}
+
+	if err := fs.file.Truncate(entry.Offset); err != nil {
+		return err
+	}
+
+	if err := fs.file.Sync(); err != nil {
+		return err
+	}
+
+	return fs.index.truncateFrom(height)
+}
+
+func (fs *fileBlockStore) Close() error {
+	if err := fs.index.close(); err != nil {
+		return err
+	}
+
+	return fs.file.Close()
+}
+
+func (fs *fileBlockStore) read(entry blockIndexEntry) (BlockFS, error) {
+	record := make([]byte, entry.Length)
+	if _, err := fs.file.ReadAt(record, entry.Offset); err != nil {
+		return BlockFS{}, err
+	}
+
+	blockFs, err := decodeBlockRecord(record)
+	if err != nil {
+		return BlockFS{}, err
+	}
+
+	if blockFs.Key != entry.Hash {
+		return BlockFS{}, fmt.Errorf("blocks index is out of sync. expected block '%x' not '%x'", entry.Hash, blockFs.Key)
+	}
+
+	return blockFs, nil
+}
+
+func encodeBlockRecord(blockFs BlockFS) ([]byte, error) {
+	blockFsJson, err := json.Marshal(blockFs)
+	if err != nil {
+		return nil, err
+	}
+
+	record := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(blockFsJson), blockFsJson)
+
+	return []byte(record), nil
+}
+
+func decodeBlockRecord(record []byte) (BlockFS, error) {
+	var blockFs BlockFS
+
+	record = bytes.TrimSuffix(record, []byte{'\n'})
+
+	// legacy records, prior to checksums, are plain JSON
+	if len(record) > 0 && record[0] != '{' {
+		if len(record) < recordChecksumLength+1 || record[recordChecksumLength] != ' ' {
+			return blockFs, errors.New("malformed block record")
+		}
+
+		checksum, err := strconv.ParseUint(string(record[:recordChecksumLength]), 16, 32)
+		if err != nil {
+			return blockFs, fmt.Errorf("malformed block record checksum. %s", err.Error())
+		}
+
+		record = record[recordChecksumLength+1:]
+		if uint32(checksum) != crc32.ChecksumIEEE(record) {
+			return blockFs, errors.New("block record checksum mismatch")
+		}
+	}
+
+	if err := json.Unmarshal(record, &blockFs); err != nil {
+		return blockFs, err
+	}
+
+	return blockFs, nil
+}
+
+// recoverTornRecord cuts off the end of the file if a crash left the last record incompletely written.
+//
+// Records are written in a single write ending with a new line, so a torn record is one missing its new line.
+// A complete record that doesn't verify isn't the result of an interrupted write, the store refuses to open
+// rather than dropping a block. The data dir lock makes sure no other process is appending the record.
+func recoverTornRecord(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		return nil
+	}
+
+	lastLineEnd, err := lastIndexOfNewLine(f, size)
+	if err != nil {
+		return err
+	}
+
+	validSize := lastLineEnd + 1
+	if validSize < size {
+		fmt.Printf("warning: %s ends with a partially written block, most likely due to a crash. truncating %d bytes\n", f.Name(), size-validSize)
+
+		if err := f.Truncate(validSize); err != nil {
+			return err
+		}
+
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+
+	if validSize == 0 {
+		return nil
+	}
+
+	// the file now ends with a complete line, make sure it's a valid record
+	recordStart, err := lastIndexOfNewLine(f, validSize-1)
+	if err != nil {
+		return err
+	}
+
+	record := make([]byte, validSize-(recordStart+1))
+	if _, err := f.ReadAt(record, recordStart+1); err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(record)) == 0 {
+		return nil
+	}
+
+	if _, err := decodeBlockRecord(record); err != nil {
+		return fmt.Errorf("%s last block record, at offset %d, is corrupted. restore the file from a backup or resync the node. %s", f.Name(), recordStart+1, err.Error())
+	}
+
+	return nil
+}
+
+// lastIndexOfNewLine returns the position of the last new line before the given offset, or -1 if there's none.
+func lastIndexOfNewLine(f *os.File, before int64) (int64, error) {
+	chunk := make([]byte, 4096)
+
+	for end := before; end > 0; {
+		start := end - int64(len(chunk))
+		if start < 0 {
+			start = 0
+		}
+
+		n, err := f.ReadAt(chunk[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		if i := bytes.LastIndexByte(chunk[:n], '\n'); i >= 0 {
+			return start + int64(i), nil
+		}
+
+		end = start
+	}
+
+	return -1, nil
+}
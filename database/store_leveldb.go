@@ -0,0 +1,209 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var (
+	levelDBBlockPrefix  = []byte("b") // b + hash -> BlockFS JSON
+	levelDBHeightPrefix = []byte("n") // n + big endian height -> hash
+	levelDBTipKey       = []byte("tip")
+)
+
+// levelDBBlockStore keeps the blocks in a LevelDB database, indexed by hash and height.
+type levelDBBlockStore struct {
+	db *leveldb.DB
+}
+
+func openLevelDBBlockStore(path string) (*levelDBBlockStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelDBBlockStore{db: db}, nil
+}
+
+func (ls *levelDBBlockStore) Append(blockFs BlockFS) error {
+	blockFsJson, err := json.Marshal(blockFs)
+	if err != nil {
+		return err
+	}
+
+	if err := ls.validateNext(blockFs); err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(levelDBBlockKey(blockFs.Key), blockFsJson)
+	batch.Put(levelDBHeightKey(blockFs.Value.Header.Number), blockFs.Key[:])
+	batch.Put(levelDBTipKey, blockFs.Key[:])
+
+	return ls.db.Write(batch, &opt.WriteOptions{Sync: true})
+}
+
+// validateNext checks the block is a new one, stored right on top of the tip, as the file store does.
+//
+// Heights are stored without gaps, so the tip is the block whose height has no successor.
+func (ls *levelDBBlockStore) validateNext(blockFs BlockFS) error {
+	number := blockFs.Value.Header.Number
+
+	taken, err := ls.db.Has(levelDBHeightKey(number), nil)
+	if err != nil {
+		return err
+	}
+
+	follows := number == 0
+	if !follows {
+		if follows, err = ls.db.Has(levelDBHeightKey(number-1), nil); err != nil {
+			return err
+		}
+	}
+
+	if taken || !follows {
+		expected := uint64(0)
+		if tip, err := ls.Tip(); err == nil {
+			expected = tip.Value.Header.Number + 1
+		}
+
+		return fmt.Errorf("blocks store expected block number '%d' not '%d'", expected, number)
+	}
+
+	known, err := ls.db.Has(levelDBBlockKey(blockFs.Key), nil)
+	if err != nil {
+		return err
+	}
+
+	if known {
+		return fmt.Errorf("blocks store already contains block '%x'", blockFs.Key)
+	}
+
+	return nil
+}
+
+func (ls *levelDBBlockStore) GetByHash(hash Hash) (BlockFS, error) {
+	blockFsJson, err := ls.db.Get(levelDBBlockKey(hash), nil)
+	if err == leveldb.ErrNotFound {
+		return BlockFS{}, ErrBlockNotFound
+	}
+
+	if err != nil {
+		return BlockFS{}, err
+	}
+
+	var blockFs BlockFS
+	if err := json.Unmarshal(blockFsJson, &blockFs); err != nil {
+		return BlockFS{}, err
+	}
+
+	return blockFs, nil
+}
+
+func (ls *levelDBBlockStore) GetByHeight(height uint64) (BlockFS, error) {
+	hash, err := ls.getHash(levelDBHeightKey(height))
+	if err != nil {
+		return BlockFS{}, err
+	}
+
+	return ls.GetByHash(hash)
+}
+
+func (ls *levelDBBlockStore) Iterate(fromHeight uint64, fn func(blockFs BlockFS) error) error {
+	heights := &util.Range{
+		Start: levelDBHeightKey(fromHeight),
+		Limit: util.BytesPrefix(levelDBHeightPrefix).Limit,
+	}
+
+	it := ls.db.NewIterator(heights, nil)
+	defer it.Release()
+
+	for it.Next() {
+		var hash Hash
+		copy(hash[:], it.Value())
+
+		blockFs, err := ls.GetByHash(hash)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(blockFs); err != nil {
+			return err
+		}
+	}
+
+	return it.Error()
+}
+
+func (ls *levelDBBlockStore) Tip() (BlockFS, error) {
+	hash, err := ls.getHash(levelDBTipKey)
+	if err != nil {
+		return BlockFS{}, err
+	}
+
+	return ls.GetByHash(hash)
+}
+
+func (ls *levelDBBlockStore) TruncateFrom(height uint64) error {
+	batch := new(leveldb.Batch)
+
+	err := ls.Iterate(height, func(blockFs BlockFS) error {
+		batch.Delete(levelDBBlockKey(blockFs.Key))
+		batch.Delete(levelDBHeightKey(blockFs.Value.Header.Number))
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if height == 0 {
+		batch.Delete(levelDBTipKey)
+	} else {
+		newTip, err := ls.getHash(levelDBHeightKey(height - 1))
+		if err != nil {
+			return err
+		}
+
+		batch.Put(levelDBTipKey, newTip[:])
+	}
+
+	return ls.db.Write(batch, &opt.WriteOptions{Sync: true})
+}
+
+func (ls *levelDBBlockStore) Close() error {
+	return ls.db.Close()
+}
+
+func (ls *levelDBBlockStore) getHash(key []byte) (Hash, error) {
+	value, err := ls.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return Hash{}, ErrBlockNotFound
+	}
+
+	if err != nil {
+		return Hash{}, err
+	}
+
+	var hash Hash
+	copy(hash[:], value)
+
+	return hash, nil
+}
+
+func levelDBBlockKey(hash Hash) []byte {
+	return append(append([]byte{}, levelDBBlockPrefix...), hash[:]...)
+}
+
+func levelDBHeightKey(height uint64) []byte {
+	key := make([]byte, len(levelDBHeightPrefix)+8)
+	copy(key, levelDBHeightPrefix)
+	binary.BigEndian.PutUint64(key[len(levelDBHeightPrefix):], height)
+
+	return key
+}
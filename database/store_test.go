@@ -0,0 +1,277 @@
+package database
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestBlockStore(t *testing.T) {
+	for _, backend := range []string{BackendFile, BackendLevelDB} {
+		t.Run(backend, func(t *testing.T) {
+			dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_store_test")
+			require.NoError(t, err)
+			defer utils.RemoveDir(dataDir)
+
+			require.NoError(t, initDataDir(dataDir, []byte(genesisJson), backend))
+			assert.Equal(t, backend, DetectBlockStoreBackend(dataDir))
+
+			store, err := openBlockStore(dataDir, backend)
+			require.NoError(t, err)
+
+			_, err = store.Tip()
+			assert.Equal(t, ErrBlockNotFound, err)
+
+			blocks := appendTestBlocks(t, store, 5)
+			assertStoreContains(t, store, blocks)
+
+			// Only a new block right on top of the tip is appended
+			skipping := NewBlock(blocks[4].Key, 6, 0, 0, NewAccount(""), []SignedTx{})
+			skippingHash, err := skipping.Hash()
+			require.NoError(t, err)
+			assert.Error(t, store.Append(BlockFS{Key: skippingHash, Value: skipping}))
+
+			overwriting := NewBlock(blocks[2].Key, 3, 1, 0, NewAccount(""), []SignedTx{})
+			overwritingHash, err := overwriting.Hash()
+			require.NoError(t, err)
+			assert.Error(t, store.Append(BlockFS{Key: overwritingHash, Value: overwriting}))
+
+			known := blocks[0]
+			known.Value.Header.Number = 5
+			assert.Error(t, store.Append(known))
+
+			assertStoreContains(t, store, blocks)
+			require.NoError(t, store.Close())
+
+			// Reopen and check everything was persisted, for the file backend without the index too
+			if backend == BackendFile {
+				require.NoError(t, os.Remove(getBlocksIndexFilePath(getBlocksDbFilePath(dataDir))))
+			}
+
+			store, err = openBlockStore(dataDir, backend)
+			require.NoError(t, err)
+			assertStoreContains(t, store, blocks)
+			require.NoError(t, store.Close())
+		})
+	}
+}
+
+func TestMigrateBlockStore(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_store_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	require.NoError(t, InitDataDirIfNotExists(dataDir, []byte(genesisJson)))
+
+	store, err := openBlockStore(dataDir, BackendFile)
+	require.NoError(t, err)
+	blocks := appendTestBlocks(t, store, 3)
+	require.NoError(t, store.Close())
+
+	for _, to := range []string{BackendLevelDB, BackendFile} {
+		require.NoError(t, MigrateBlockStore(dataDir, to))
+		assert.Equal(t, to, DetectBlockStoreBackend(dataDir))
+		assert.NoError(t, ConfigureBlockStore(dataDir, to))
+
+		store, err = openBlockStore(dataDir, to)
+		require.NoError(t, err)
+		assertStoreContains(t, store, blocks)
+		require.NoError(t, store.Close())
+	}
+
+	assert.Error(t, MigrateBlockStore(dataDir, BackendFile))
+	assert.Error(t, ConfigureBlockStore(dataDir, BackendLevelDB))
+}
+
+func appendTestBlocks(t *testing.T, store BlockStore, count uint64) []BlockFS {
+	return appendTestBlocksOn(t, store, Hash{}, 0, count)
+}
+
+// appendTestBlocksOn appends count blocks on top of the parent, the first one at the given height.
+func appendTestBlocksOn(t *testing.T, store BlockStore, parent Hash, height uint64, count uint64) []BlockFS {
+	blocks := make([]BlockFS, 0)
+
+	for i := height; i < height+count; i++ {
+		block := NewBlock(parent, i, uint32(i), i, NewAccount(""), []SignedTx{})
+		hash, err := block.Hash()
+		require.NoError(t, err)
+
+		blockFs := BlockFS{Key: hash, Value: block}
+		require.NoError(t, store.Append(blockFs))
+
+		blocks = append(blocks, blockFs)
+		parent = hash
+	}
+
+	return blocks
+}
+
+func assertStoreContains(t *testing.T, store BlockStore, blocks []BlockFS) {
+	for height, blockFs := range blocks {
+		byHeight, err := store.GetByHeight(uint64(height))
+		assert.NoError(t, err)
+		assert.Equal(t, blockFs.Key, byHeight.Key)
+
+		byHash, err := store.GetByHash(blockFs.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(height), byHash.Value.Header.Number)
+	}
+
+	_, err := store.GetByHeight(uint64(len(blocks)))
+	assert.Equal(t, ErrBlockNotFound, err)
+
+	tip, err := store.Tip()
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[len(blocks)-1].Key, tip.Key)
+
+	iterated := make([]BlockFS, 0)
+	err = store.Iterate(1, func(blockFs BlockFS) error {
+		iterated = append(iterated, blockFs)
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(blocks)-1, len(iterated))
+	assert.Equal(t, blocks[1].Key, iterated[0].Key)
+}
+
+func TestFileBlockStore_RecoversTornRecord(t *testing.T) {
+	testCases := map[string]func(record []byte) []byte{
+		"partial record": func(record []byte) []byte {
+			return record[:len(record)/2]
+		},
+		"partial record with checksum only": func(record []byte) []byte {
+			return record[:recordChecksumLength+1]
+		},
+		"zeroed tail": func(record []byte) []byte {
+			return make([]byte, len(record))
+		},
+	}
+
+	for name, tornRecord := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_store_test")
+			require.NoError(t, err)
+			defer utils.RemoveDir(dataDir)
+
+			require.NoError(t, InitDataDirIfNotExists(dataDir, []byte(genesisJson)))
+			dbPath := getBlocksDbFilePath(dataDir)
+
+			// A legacy record, without checksum, followed by the new format ones
+			legacy := NewBlock(Hash{}, 0, 0, 0, NewAccount(""), []SignedTx{})
+			legacyHash, err := legacy.Hash()
+			require.NoError(t, err)
+			legacyJson, err := json.Marshal(BlockFS{Key: legacyHash, Value: legacy})
+			require.NoError(t, err)
+			require.NoError(t, ioutil.WriteFile(dbPath, append(legacyJson, '\n'), 0600))
+
+			store, err := openFileBlockStore(dbPath)
+			require.NoError(t, err)
+
+			next := NewBlock(legacyHash, 1, 0, 0, NewAccount(""), []SignedTx{})
+			nextHash, err := next.Hash()
+			require.NoError(t, err)
+			require.NoError(t, store.Append(BlockFS{Key: nextHash, Value: next}))
+			require.NoError(t, store.Close())
+
+			// Simulate a crash in the middle of writing the third block
+			torn := NewBlock(nextHash, 2, 0, 0, NewAccount(""), []SignedTx{})
+			tornHash, err := torn.Hash()
+			require.NoError(t, err)
+			record, err := encodeBlockRecord(BlockFS{Key: tornHash, Value: torn})
+			require.NoError(t, err)
+
+			f, err := os.OpenFile(dbPath, os.O_APPEND|os.O_WRONLY, 0600)
+			require.NoError(t, err)
+			_, err = f.Write(tornRecord(record))
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			store, err = openFileBlockStore(dbPath)
+			require.NoError(t, err)
+
+			tip, err := store.Tip()
+			require.NoError(t, err)
+			assert.Equal(t, nextHash, tip.Key)
+
+			// The store is writable again right after the recovered block
+			assert.NoError(t, store.Append(BlockFS{Key: tornHash, Value: torn}))
+			require.NoError(t, store.Close())
+
+			store, err = openFileBlockStore(dbPath)
+			require.NoError(t, err)
+			assertStoreContains(t, store, []BlockFS{{Key: legacyHash, Value: legacy}, {Key: nextHash, Value: next}, {Key: tornHash, Value: torn}})
+			require.NoError(t, store.Close())
+		})
+	}
+}
+
+func TestFileBlockStore_RefusesCorruptedRecord(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_store_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	require.NoError(t, InitDataDirIfNotExists(dataDir, []byte(genesisJson)))
+	dbPath := getBlocksDbFilePath(dataDir)
+
+	store, err := openFileBlockStore(dbPath)
+	require.NoError(t, err)
+	appendTestBlocks(t, store, 2)
+	require.NoError(t, store.Close())
+
+	// A complete record failing its checksum isn't a torn write, it must not be dropped silently
+	records, err := ioutil.ReadFile(dbPath)
+	require.NoError(t, err)
+	records[len(records)-10] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(dbPath, records, 0600))
+
+	_, err = openFileBlockStore(dbPath)
+	assert.Error(t, err)
+
+	after, err := ioutil.ReadFile(dbPath)
+	require.NoError(t, err)
+	assert.Equal(t, records, after)
+}
+
+func TestFileBlockStore_RejectsBlockBeforeWriting(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_store_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	require.NoError(t, InitDataDirIfNotExists(dataDir, []byte(genesisJson)))
+	dbPath := getBlocksDbFilePath(dataDir)
+
+	store, err := openFileBlockStore(dbPath)
+	require.NoError(t, err)
+	blocks := appendTestBlocks(t, store, 2)
+
+	dbInfo, err := os.Stat(dbPath)
+	require.NoError(t, err)
+
+	// A block skipping a height, and a block already stored at the next height
+	skipping := NewBlock(blocks[1].Key, 3, 0, 0, NewAccount(""), []SignedTx{})
+	skippingHash, err := skipping.Hash()
+	require.NoError(t, err)
+	assert.Error(t, store.Append(BlockFS{Key: skippingHash, Value: skipping}))
+
+	known := blocks[0]
+	known.Value.Header.Number = 2
+	assert.Error(t, store.Append(known))
+
+	// block.db is left as it was, so the index still matches it on the next start
+	afterInfo, err := os.Stat(dbPath)
+	require.NoError(t, err)
+	assert.Equal(t, dbInfo.Size(), afterInfo.Size())
+	require.NoError(t, store.Close())
+
+	store, err = openFileBlockStore(dbPath)
+	require.NoError(t, err)
+	assertStoreContains(t, store, blocks)
+	assert.Equal(t, dbInfo.Size(), store.index.dbSize)
+	require.NoError(t, store.Close())
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"math/big"
+)
+
+// From the TIP-5 fork on, a block hash is valid when, read as a 256-bit big endian number, it's lower than the target
+// encoded in the header "bits", the compact format of Bitcoin:
+//
+//	bits = exponent (1 byte) | mantissa (3 bytes), target = mantissa * 256^(exponent-3)
+//
+// Unlike the legacy difficulty, counting leading zero bytes, the target can be adjusted by any factor.
+const (
+	compactSignBit   = 0x00800000
+	compactMantissa  = 0x007fffff
+	retargetMaxShift = 4 // a retarget changes the target by a factor of 4 at most
+)
+
+// maxTarget is the easiest target, equivalent to the legacy difficulty 1.
+var maxTarget = difficultyToTarget(1)
+
+// CompactToTarget decodes the compact bits into the target. Negative or overflowing bits decode to a zero target,
+// no hash is lower than.
+func CompactToTarget(bits uint32) *big.Int {
+	exponent := uint(bits >> 24)
+	mantissa := int64(bits & compactMantissa)
+
+	if bits&compactSignBit != 0 || exponent > 32+3 {
+		return big.NewInt(0)
+	}
+
+	target := big.NewInt(mantissa)
+	if exponent <= 3 {
+		return target.Rsh(target, 8*(3-exponent))
+	}
+
+	target.Lsh(target, 8*(exponent-3))
+	if target.BitLen() > 256 {
+		return big.NewInt(0)
+	}
+
+	return target
+}
+
+// TargetToCompact encodes the target into compact bits, keeping its 3 most significant bytes.
+func TargetToCompact(target *big.Int) uint32 {
+	exponent := uint((target.BitLen() + 7) / 8)
+
+	var mantissa uint64
+	if exponent <= 3 {
+		mantissa = new(big.Int).Lsh(target, 8*(3-exponent)).Uint64()
+	} else {
+		mantissa = new(big.Int).Rsh(target, 8*(exponent-3)).Uint64()
+	}
+
+	// the mantissa's highest bit would read as a negative sign, move it to the next byte
+	if mantissa&compactSignBit != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	return uint32(exponent)<<24 | uint32(mantissa)
+}
+
+// DifficultyToBits returns the bits of the target equivalent to the legacy difficulty:
+// a hash with that many leading zero bytes.
+func DifficultyToBits(difficulty uint) uint32 {
+	return TargetToCompact(difficultyToTarget(difficulty))
+}
+
+// IsBlockHashBelowTarget tells whether the hash satisfies the target encoded in the compact bits.
+func IsBlockHashBelowTarget(hash Hash, bits uint32) bool {
+	return new(big.Int).SetBytes(hash[:]).Cmp(CompactToTarget(bits)) < 0
+}
+
+// TargetWork is the proof-of-work a block mined at the target represents, 2^256 / target hashes on average.
+func TargetWork(bits uint32) *big.Int {
+	target := CompactToTarget(bits)
+	if target.Sign() == 0 {
+		return big.NewInt(0)
+	}
+
+	return new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), target)
+}
+
+// retargetBits scales the target by the ratio between the time the retarget interval took and the expected time,
+// by a factor of 4 at most, and never makes it easier than the easiest target.
+func retargetBits(bits uint32, elapsed uint64, expected uint64) uint32 {
+	if elapsed < expected/retargetMaxShift {
+		elapsed = expected / retargetMaxShift
+	}
+
+	if elapsed > expected*retargetMaxShift {
+		elapsed = expected * retargetMaxShift
+	}
+
+	if elapsed == 0 {
+		elapsed = 1
+	}
+
+	target := CompactToTarget(bits)
+	target.Mul(target, new(big.Int).SetUint64(elapsed))
+	target.Div(target, new(big.Int).SetUint64(expected))
+
+	if target.Cmp(maxTarget) > 0 {
+		target = maxTarget
+	}
+
+	return TargetToCompact(target)
+}
+
+func difficultyToTarget(difficulty uint) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), 256-8*difficulty)
+}
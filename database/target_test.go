@@ -0,0 +1,170 @@
+package database
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestCompactTarget(t *testing.T) {
+	testCases := map[string]struct {
+		bits       uint32
+		wantTarget string
+	}{
+		"bitcoin genesis": {bits: 0x1d00ffff, wantTarget: "00000000ffff0000000000000000000000000000000000000000000000000000"},
+		"difficulty 1":    {bits: 0x20010000, wantTarget: "0100000000000000000000000000000000000000000000000000000000000000"},
+		"difficulty 2":    {bits: 0x1f010000, wantTarget: "0001000000000000000000000000000000000000000000000000000000000000"},
+		"small exponent":  {bits: 0x02123400, wantTarget: "0000000000000000000000000000000000000000000000000000000000001234"},
+		"negative":        {bits: 0x1d800000, wantTarget: "0000000000000000000000000000000000000000000000000000000000000000"},
+		"overflow":        {bits: 0x23010000, wantTarget: "0000000000000000000000000000000000000000000000000000000000000000"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			wantTarget, ok := new(big.Int).SetString(tc.wantTarget, 16)
+			require.True(t, ok)
+
+			target := CompactToTarget(tc.bits)
+			assert.Equal(t, 0, wantTarget.Cmp(target), "target %x", target)
+
+			if target.Sign() > 0 {
+				assert.Equal(t, tc.bits, TargetToCompact(target))
+			}
+		})
+	}
+
+	// The mantissa can't have its sign bit set
+	assert.Equal(t, uint32(0x2100ff00), TargetToCompact(new(big.Int).Lsh(big.NewInt(0xff), 248)))
+}
+
+func TestDifficultyToBits(t *testing.T) {
+	testCases := map[string]struct {
+		hexHash      string
+		difficulty   uint
+		wantValid    bool
+		wantValidOld bool
+	}{
+		"valid": {
+			hexHash:      "0000fa04f8160395c387277f8b2f14837603383d33809a4db586086168edfa00",
+			difficulty:   2,
+			wantValid:    true,
+			wantValidOld: true,
+		},
+		"invalid": {
+			hexHash:      "0001fa04f8160395c387277f8b2f14837603383d33809a4db586086168edfa00",
+			difficulty:   2,
+			wantValid:    false,
+			wantValidOld: false,
+		},
+		// the legacy rule rejects hashes with more zero bytes than the difficulty, a target doesn't
+		"more zeroes than required": {
+			hexHash:      "000000a4f8160395c387277f8b2f14837603383d33809a4db586086168edfa00",
+			difficulty:   2,
+			wantValid:    true,
+			wantValidOld: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var hash = Hash{}
+			_, err := hex.Decode(hash[:], []byte(tc.hexHash))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantValid, IsBlockHashBelowTarget(hash, DifficultyToBits(tc.difficulty)))
+			assert.Equal(t, tc.wantValidOld, IsBlockHashValid(hash, tc.difficulty))
+			assert.Equal(t, BlockWork(tc.difficulty), TargetWork(DifficultyToBits(tc.difficulty)))
+		})
+	}
+}
+
+func TestRetargetBits(t *testing.T) {
+	bits := DifficultyToBits(2)
+	target := CompactToTarget(bits)
+
+	testCases := map[string]struct {
+		elapsed    uint64
+		wantTarget *big.Int
+	}{
+		"on target":     {elapsed: 100, wantTarget: target},
+		"twice as fast": {elapsed: 50, wantTarget: new(big.Int).Rsh(target, 1)},
+		"way too fast":  {elapsed: 0, wantTarget: new(big.Int).Rsh(target, 2)},
+		"twice as slow": {elapsed: 200, wantTarget: new(big.Int).Lsh(target, 1)},
+		"way too slow":  {elapsed: 10000, wantTarget: new(big.Int).Lsh(target, 2)},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, 0, tc.wantTarget.Cmp(CompactToTarget(retargetBits(bits, tc.elapsed, 100))))
+		})
+	}
+
+	// The target never gets easier than difficulty 1
+	assert.Equal(t, DifficultyToBits(1), retargetBits(DifficultyToBits(1), 10000, 100))
+}
+
+func TestState_TargetFork(t *testing.T) {
+	forkTIP4 := uint64(1)
+	forkTIP5 := uint64(2)
+	s, key, sender := newTestState(t, Genesis{
+		ForkTIP1:         0,
+		ForkTIP4:         &forkTIP4,
+		ForkTIP5:         &forkTIP5,
+		MiningDifficulty: 1,
+		TargetBlockTime:  60,
+		RetargetInterval: 3,
+	})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+	nonce := uint(0)
+	nextTx := func() []SignedTx {
+		nonce++
+
+		return []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), nonce, ""), key)}
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := s.AddBlock(mineTestBlock(t, s, sender, nextTx()))
+		require.NoError(t, err)
+	}
+
+	// From the fork on, the target equivalent to the last difficulty is recorded as bits
+	txs := nextTx()
+	block := mineTestBlock(t, s, sender, txs)
+	assert.Equal(t, DifficultyToBits(1), block.Header.Bits)
+	assert.Equal(t, uint(0), block.Header.Difficulty)
+
+	legacy := block
+	legacy.Header.Bits = 0
+	legacy.Header.Difficulty = 1
+	_, err := s.AddBlock(mineTestBlockWithHeader(t, s, legacy))
+	assert.Error(t, err)
+
+	easier := block
+	easier.Header.Bits = TargetToCompact(new(big.Int).Lsh(CompactToTarget(block.Header.Bits), 1))
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, easier))
+	assert.Error(t, err)
+
+	_, err = s.AddBlock(block)
+	require.NoError(t, err)
+
+	// The blocks are mined way faster than the target block time, the retarget makes the target 4 times harder
+	block = mineTestBlock(t, s, sender, nextTx())
+	block.Header.Time = s.retargetStartTime
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, block))
+	require.NoError(t, err)
+
+	expectedTarget := new(big.Int).Rsh(CompactToTarget(DifficultyToBits(1)), 2)
+	assert.Equal(t, 0, expectedTarget.Cmp(CompactToTarget(s.NextBlockBits())))
+
+	totalWork := s.TotalWork()
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, nextTx()))
+	require.NoError(t, err)
+	assert.Equal(t, new(big.Int).Add(totalWork, TargetWork(TargetToCompact(expectedTarget))), s.TotalWork())
+}
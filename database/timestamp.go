@@ -0,0 +1,142 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// DefaultMaxBlockTimeDrift is how far, in seconds, a block time can be ahead of the local clock
+	DefaultMaxBlockTimeDrift = 2 * 60 * 60
+	medianTimeSpan           = 11  // a block time must be later than the median time of that many last blocks
+	futureBlocksMax          = 100 // how many blocks from the future are queued at most
+)
+
+// MedianTimePast returns the median time of the last main chain blocks. From the TIP-6 fork on,
+// the next block time must be later, so the chain time only moves forward even if a few miners' clocks are wrong.
+func (s *State) MedianTimePast() uint64 {
+	if len(s.recentBlockTimes) == 0 {
+		return 0
+	}
+
+	times := make([]uint64, len(s.recentBlockTimes))
+	copy(times, s.recentBlockTimes)
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+
+	return times[len(times)/2]
+}
+
+// ImportQueuedBlocks imports the queued blocks from the future whose time came, oldest first.
+// The blocks failing to import are forgotten.
+func (s *State) ImportQueuedBlocks() []ChainUpdate {
+	due := make([]BlockFS, 0)
+	for hash, b := range s.futureBlocks {
+		if !s.isFromFuture(b) {
+			due = append(due, BlockFS{Key: hash, Value: b})
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Value.Header.Number < due[j].Value.Header.Number
+	})
+
+	updates := make([]ChainUpdate, 0, len(due))
+	for _, blockFs := range due {
+		delete(s.futureBlocks, blockFs.Key)
+
+		update, err := s.ImportBlock(blockFs.Value)
+		if err != nil {
+			fmt.Printf("queued block '%x' is invalid: %s\n", blockFs.Key, err.Error())
+
+			continue
+		}
+
+		updates = append(updates, update)
+	}
+
+	return updates
+}
+
+// isFromFuture tells whether the block time is too far ahead of the local clock for the block to be imported yet.
+func (s *State) isFromFuture(b Block) bool {
+	return b.Header.Time > uint64(s.clock().Unix())+s.maxBlockTimeDrift
+}
+
+// queueFutureBlock keeps the block from the future until its time comes, instead of rejecting it for good.
+func (s *State) queueFutureBlock(b Block, hash Hash) (ChainUpdate, error) {
+	if !s.isBlockHashValid(hash, b) {
+		return ChainUpdate{}, newBlockError(b.Header.Number, ErrBadPoW, "invalid block hash %x", hash)
+	}
+
+	// The block may fork from the main chain as deep as side chains are kept for
+	forkHeight := uint64(0)
+	if s.hasGenesisBlock && s.latestBlock.Header.Number > sideChainMaxDepth {
+		forkHeight = s.latestBlock.Header.Number - sideChainMaxDepth
+	}
+
+	if forkHeight > b.Header.Number {
+		forkHeight = b.Header.Number
+	}
+
+	easiestTarget, err := s.easiestSideBlockTarget(forkHeight, b.Header.Number)
+	if err != nil {
+		return ChainUpdate{}, err
+	}
+
+	if s.targetOf(b).Cmp(easiestTarget) > 0 {
+		return ChainUpdate{}, newBlockError(b.Header.Number, ErrBadDifficulty, "block '%x' target is easier than the chain can have at its height", hash)
+	}
+
+	if _, ok := s.futureBlocks[hash]; !ok && len(s.futureBlocks) >= futureBlocksMax {
+		return ChainUpdate{}, newBlockError(b.Header.Number, ErrBadBlockTime, "block '%x' time %d is ahead of the local clock and the queue of blocks from the future is full", hash, b.Header.Time)
+	}
+
+	fmt.Printf("block '%x' time %d is ahead of the local clock, queueing it until then\n", hash, b.Header.Time)
+	s.futureBlocks[hash] = b
+
+	return ChainUpdate{Hash: hash, Queued: true}, nil
+}
+
+// trackBlockTime remembers the time of the last main chain blocks, once the block is applied.
+func (s *State) trackBlockTime(b Block) {
+	start := 0
+	if len(s.recentBlockTimes) >= medianTimeSpan {
+		start = len(s.recentBlockTimes) - medianTimeSpan + 1
+	}
+
+	// a new slice, the previous one may be shared with a copy of the state
+	times := make([]uint64, 0, medianTimeSpan)
+	times = append(times, s.recentBlockTimes[start:]...)
+	s.recentBlockTimes = append(times, b.Header.Time)
+}
+
+// loadRecentBlockTimes reads the time of the last main chain blocks up to the given height from the block store.
+func (s *State) loadRecentBlockTimes(height uint64) error {
+	from := uint64(0)
+	if height >= medianTimeSpan {
+		from = height - medianTimeSpan + 1
+	}
+
+	s.recentBlockTimes = make([]uint64, 0, medianTimeSpan)
+	for number := from; number <= height; number++ {
+		blockFs, err := s.db.GetByHeight(number)
+		if err != nil {
+			return err
+		}
+
+		s.recentBlockTimes = append(s.recentBlockTimes, blockFs.Value.Header.Time)
+	}
+
+	return nil
+}
+
+func (b Block) validateTime(medianTimePast uint64, isTIP6Fork bool) error {
+	if isTIP6Fork && b.Header.Time <= medianTimePast {
+		return newBlockMismatchError(b.Header.Number, ErrBadBlockTime, medianTimePast, b.Header.Time, "block '%d' time %d must be later than the median time %d of the last blocks", b.Header.Number, b.Header.Time, medianTimePast)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+	"time"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestState_MedianTimePast(t *testing.T) {
+	forkTIP6 := uint64(0)
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0, ForkTIP6: &forkTIP6})
+	defer utils.RemoveDir(s.dataDir)
+
+	s.snapshotInterval = 4
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+	start := uint64(time.Now().Unix()) - 1000
+
+	// 12 blocks, 10 seconds apart, except the 9th stamped way before its parent by a miner with a wrong clock
+	for nonce := uint(1); nonce <= 12; nonce++ {
+		block := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), nonce, ""), key)})
+		block.Header.Time = start + uint64(nonce)*10
+		if nonce == 9 {
+			block.Header.Time = start + 65
+		}
+
+		_, err := s.AddBlock(mineTestBlockWithHeader(t, s, block))
+		require.NoError(t, err)
+	}
+
+	// the times of the last 11 blocks are 20..80, 65, 100..120, sorted 20..60, 65, 70..120
+	assert.Equal(t, start+65, s.MedianTimePast())
+
+	block := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 13, ""), key)})
+	block.Header.Time = start + 65
+	_, err := s.AddBlock(mineTestBlockWithHeader(t, s, block))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "median time")
+
+	block.Header.Time = start + 66
+	_, err = s.AddBlock(mineTestBlockWithHeader(t, s, block))
+	require.NoError(t, err)
+
+	// The median is the same once restarted from a snapshot
+	expectedMedian := s.MedianTimePast()
+	require.NoError(t, s.Close())
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	assert.Equal(t, expectedMedian, restarted.MedianTimePast())
+}
+
+func TestState_QueuesBlocksFromFuture(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	_, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)}))
+	require.NoError(t, err)
+	tip := s.LatestBlockHash()
+
+	future := mineTestBlock(t, s, sender, []SignedTx{signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 2, ""), key)})
+	future.Header.Time = uint64(time.Now().Unix()) + DefaultMaxBlockTimeDrift + 60
+	future = mineTestBlockWithHeader(t, s, future)
+
+	update, err := s.ImportBlock(future)
+	require.NoError(t, err)
+	assert.True(t, update.Queued)
+	assert.Equal(t, tip, s.LatestBlockHash())
+
+	// Nothing to import until the block time comes
+	assert.Empty(t, s.ImportQueuedBlocks())
+
+	s.clock = func() time.Time {
+		return time.Now().Add(time.Minute * 2)
+	}
+
+	updates := s.ImportQueuedBlocks()
+	require.Len(t, updates, 1)
+	assert.Equal(t, []Block{future}, updates[0].Applied)
+	assert.Equal(t, update.Hash, s.LatestBlockHash())
+	assert.Empty(t, s.futureBlocks)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/elliptic"
 	"crypto/sha256"
 	"encoding/json"
+	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -15,46 +16,54 @@ func NewAccount(value string) common.Address {
 	return common.HexToAddress(value)
 }
 
+// Tx amounts are big integers, encoded as JSON numbers as the uint they replaced were, so older TXs keep their hash.
+// A nil amount stands for zero.
 type Tx struct {
 	From  common.Address `json:"from"`
 	To    common.Address `json:"to"`
-	Value uint           `json:"value"`
+	Value *big.Int       `json:"value"`
 	Nonce uint           `json:"nonce"`
 	Data  string         `json:"data"`
 	Time  uint64         `json:"time"`
 
-	Gas      uint `json:"gas"`
-	GasPrice uint `json:"gasPrice"`
+	Gas      *big.Int `json:"gas"`
+	GasPrice *big.Int `json:"gasPrice"`
+
+	// ChainID is the network the TX is signed for, from the TIP-8 fork on, so it can't be replayed on another one
+	ChainID string `json:"chain_id,omitempty"`
 }
 
 type SignedTx struct {
 	Tx
 	Sig []byte `json:"signature"`
+
+	// Multisig is the policy and the owners' signatures of TXs sent by multisig accounts, from the TIP-11 fork on
+	Multisig *Multisig `json:"multisig,omitempty"`
 }
 
-func NewTx(from, to common.Address, value, nonce, gas, gasPrice uint, data string) Tx {
+func NewTx(from, to common.Address, value *big.Int, nonce uint, gas, gasPrice *big.Int, data string) Tx {
 	return Tx{
-		from,
-		to,
-		value,
-		nonce,
-		data,
-		uint64(time.Now().Unix()),
-		gas,
-		gasPrice,
+		From:     from,
+		To:       to,
+		Value:    amountOrZero(value),
+		Nonce:    nonce,
+		Data:     data,
+		Time:     uint64(time.Now().Unix()),
+		Gas:      amountOrZero(gas),
+		GasPrice: amountOrZero(gasPrice),
 	}
 }
 
-func NewBaseTx(from, to common.Address, value, nonce uint, data string) Tx {
-	return NewTx(from, to, value, nonce, TxGas, TxGasPriceDefault, data)
+func NewBaseTx(from, to common.Address, value *big.Int, nonce uint, data string) Tx {
+	return NewTx(from, to, value, nonce, amount(TxGas), amount(TxGasPriceDefault), data)
 }
 
 func NewSignedTx(tx Tx, sig []byte) SignedTx {
-	return SignedTx{tx, sig}
+	return SignedTx{Tx: tx, Sig: sig}
 }
 
 func (t Tx) IsReward() bool {
-	return t.Data == "reward"
+	return t.Data == CoinbaseTxData
 }
 
 func (t Tx) Encode() ([]byte, error) {
@@ -70,16 +79,23 @@ func (t Tx) Hash() (Hash, error) {
 	return sha256.Sum256(txJson), nil
 }
 
-func (t Tx) Cost(isTip1Fork bool) uint {
+// Cost is what the TX takes from the sender's balance. Computed as a big integer, so a huge gas price
+// or value can't wrap around into a cost the sender seemingly affords.
+func (t Tx) Cost(isTip1Fork bool) *big.Int {
 	if isTip1Fork {
-		return t.Value + t.GasCost()
+		return new(big.Int).Add(amountOrZero(t.Value), t.GasCost())
 	}
 
-	return t.Value + TxFee
+	return new(big.Int).Add(amountOrZero(t.Value), amount(TxFee))
+}
+
+func (t Tx) GasCost() *big.Int {
+	return new(big.Int).Mul(amountOrZero(t.Gas), amountOrZero(t.GasPrice))
 }
 
-func (t Tx) GasCost() uint {
-	return t.Gas * t.GasPrice
+// hasNegativeAmount tells whether the TX value, gas or gas price is negative, which no valid TX has.
+func (t Tx) hasNegativeAmount() bool {
+	return amountOrZero(t.Value).Sign() < 0 || amountOrZero(t.Gas).Sign() < 0 || amountOrZero(t.GasPrice).Sign() < 0
 }
 
 func (t SignedTx) Hash() (Hash, error) {
@@ -92,21 +108,34 @@ func (t SignedTx) Hash() (Hash, error) {
 }
 
 func (t SignedTx) IsAuthentic() (bool, error) {
+	if t.Multisig != nil {
+		return t.isMultisigAuthentic()
+	}
+
 	// Convert to 32 bytes hash
 	txHash, err := t.Tx.Hash()
 	if err != nil {
 		return false, err
 	}
 
-	// Recover the pub key from the tx signature and convert it to an Account
-	recoveredPubKey, err := crypto.SigToPub(txHash[:], t.Sig)
+	recoveredAccount, err := recoverSigner(txHash, t.Sig)
 	if err != nil {
 		return false, err
 	}
 
+	return recoveredAccount.Hex() == t.Tx.From.Hex(), nil
+}
+
+// recoverSigner returns the account that signed the TX hash.
+func recoverSigner(txHash Hash, sig []byte) (common.Address, error) {
+	// Recover the pub key from the tx signature and convert it to an Account
+	recoveredPubKey, err := crypto.SigToPub(txHash[:], sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+
 	recoveredPubKeyBytes := elliptic.Marshal(crypto.S256(), recoveredPubKey.X, recoveredPubKey.Y)
 	recoveredPubKeyBytesHash := crypto.Keccak256(recoveredPubKeyBytes[1:])
-	recoveredAccount := common.BytesToAddress(recoveredPubKeyBytesHash[12:])
 
-	return recoveredAccount.Hex() == t.Tx.From.Hex(), nil
+	return common.BytesToAddress(recoveredPubKeyBytesHash[12:]), nil
 }
@@ -2,6 +2,7 @@ package database
 
 import (
 	"encoding/json"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -9,24 +10,27 @@ import (
 // MarshalJSON is the main source of truth for encoding a TX for hash calculation from expected attributes.
 //
 // The logic is a bit ugly and hacky but prevents infinite marshaling loops of embedded objects
-// and allows the structure to change with new TIPs.
+// and allows the structure to change with new TIPs. The TIP-8 chain ID and the TIP-11 multisig are left out when empty,
+// keeping the hash of older TXs.
 func (t Tx) MarshalJSON() ([]byte, error) {
 	// Prior TIP1
-	if t.Gas == 0 {
+	if amountOrZero(t.Gas).Sign() == 0 {
 		return json.Marshal(struct {
-			From  common.Address `json:"from"`
-			To    common.Address `json:"to"`
-			Value uint           `json:"value"`
-			Nonce uint           `json:"nonce"`
-			Data  string         `json:"data"`
-			Time  uint64         `json:"time"`
+			From    common.Address `json:"from"`
+			To      common.Address `json:"to"`
+			Value   *big.Int       `json:"value"`
+			Nonce   uint           `json:"nonce"`
+			Data    string         `json:"data"`
+			Time    uint64         `json:"time"`
+			ChainID string         `json:"chain_id,omitempty"`
 		}{
-			From:  t.From,
-			To:    t.To,
-			Value: t.Value,
-			Nonce: t.Nonce,
-			Data:  t.Data,
-			Time:  t.Time,
+			From:    t.From,
+			To:      t.To,
+			Value:   amountOrZero(t.Value),
+			Nonce:   t.Nonce,
+			Data:    t.Data,
+			Time:    t.Time,
+			ChainID: t.ChainID,
 		})
 	}
 
@@ -34,43 +38,49 @@ func (t Tx) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		From     common.Address `json:"from"`
 		To       common.Address `json:"to"`
-		Gas      uint           `json:"gas"`
-		GasPrice uint           `json:"gasPrice"`
-		Value    uint           `json:"value"`
+		Gas      *big.Int       `json:"gas"`
+		GasPrice *big.Int       `json:"gasPrice"`
+		Value    *big.Int       `json:"value"`
 		Nonce    uint           `json:"nonce"`
 		Data     string         `json:"data"`
 		Time     uint64         `json:"time"`
+		ChainID  string         `json:"chain_id,omitempty"`
 	}{
 		From:     t.From,
 		To:       t.To,
-		Gas:      t.Gas,
-		GasPrice: t.GasPrice,
-		Value:    t.Value,
+		Gas:      amountOrZero(t.Gas),
+		GasPrice: amountOrZero(t.GasPrice),
+		Value:    amountOrZero(t.Value),
 		Nonce:    t.Nonce,
 		Data:     t.Data,
 		Time:     t.Time,
+		ChainID:  t.ChainID,
 	})
 }
 
 func (t SignedTx) MarshalJSON() ([]byte, error) {
 	// Prior TIP1
-	if t.Gas == 0 {
+	if amountOrZero(t.Gas).Sign() == 0 {
 		return json.Marshal(struct {
-			From  common.Address `json:"from"`
-			To    common.Address `json:"to"`
-			Value uint           `json:"value"`
-			Nonce uint           `json:"nonce"`
-			Data  string         `json:"data"`
-			Time  uint64         `json:"time"`
-			Sig   []byte         `json:"signature"`
+			From     common.Address `json:"from"`
+			To       common.Address `json:"to"`
+			Value    *big.Int       `json:"value"`
+			Nonce    uint           `json:"nonce"`
+			Data     string         `json:"data"`
+			Time     uint64         `json:"time"`
+			ChainID  string         `json:"chain_id,omitempty"`
+			Sig      []byte         `json:"signature"`
+			Multisig *Multisig      `json:"multisig,omitempty"`
 		}{
-			From:  t.From,
-			To:    t.To,
-			Value: t.Value,
-			Nonce: t.Nonce,
-			Data:  t.Data,
-			Time:  t.Time,
-			Sig:   t.Sig,
+			From:     t.From,
+			To:       t.To,
+			Value:    amountOrZero(t.Value),
+			Nonce:    t.Nonce,
+			Data:     t.Data,
+			Time:     t.Time,
+			ChainID:  t.ChainID,
+			Sig:      t.Sig,
+			Multisig: t.Multisig,
 		})
 	}
 
@@ -78,22 +88,26 @@ func (t SignedTx) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		From     common.Address `json:"from"`
 		To       common.Address `json:"to"`
-		Gas      uint           `json:"gas"`
-		GasPrice uint           `json:"gasPrice"`
-		Value    uint           `json:"value"`
+		Gas      *big.Int       `json:"gas"`
+		GasPrice *big.Int       `json:"gasPrice"`
+		Value    *big.Int       `json:"value"`
 		Nonce    uint           `json:"nonce"`
 		Data     string         `json:"data"`
 		Time     uint64         `json:"time"`
+		ChainID  string         `json:"chain_id,omitempty"`
 		Sig      []byte         `json:"signature"`
+		Multisig *Multisig      `json:"multisig,omitempty"`
 	}{
 		From:     t.From,
 		To:       t.To,
-		Gas:      t.Gas,
-		GasPrice: t.GasPrice,
-		Value:    t.Value,
+		Gas:      amountOrZero(t.Gas),
+		GasPrice: amountOrZero(t.GasPrice),
+		Value:    amountOrZero(t.Value),
 		Nonce:    t.Nonce,
 		Data:     t.Data,
 		Time:     t.Time,
+		ChainID:  t.ChainID,
 		Sig:      t.Sig,
+		Multisig: t.Multisig,
 	})
 }
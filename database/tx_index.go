@@ -0,0 +1,351 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TxLocation locates a main chain TX.
+type TxLocation struct {
+	TxHash      Hash   `json:"tx_hash"`
+	BlockHash   Hash   `json:"block_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	Index       uint   `json:"index"` // position of the TX in the block
+}
+
+// txIndex maps the main chain TXs by hash, and the TXs of every account, sender or receiver, oldest first.
+//
+// The index is persisted in tx.idx next to the blocks as JSON lines, one entry per main chain block,
+// and kept up to date as blocks are added or reverted. On load, only the blocks stored after the last
+// indexed one are indexed, the whole chain is read only if tx.idx is missing or doesn't match the store.
+// It's shared by the state copies, only the state owning the store updates it.
+type txIndex struct {
+	byHash    map[Hash]TxLocation
+	byAccount map[common.Address][]TxLocation
+	blocks    []txIndexEntry // by height
+
+	path string
+	file *os.File
+}
+
+type txIndexEntry struct {
+	Hash   Hash             `json:"hash"`
+	Number uint64           `json:"number"`
+	TXs    []txIndexEntryTx `json:"txs"`
+}
+
+type txIndexEntryTx struct {
+	Hash Hash           `json:"hash"`
+	From common.Address `json:"from"`
+	To   common.Address `json:"to"`
+}
+
+func newTxIndex() *txIndex {
+	return &txIndex{
+		byHash:    make(map[Hash]TxLocation),
+		byAccount: make(map[common.Address][]TxLocation),
+		blocks:    make([]txIndexEntry, 0),
+	}
+}
+
+// openTxIndex loads the index persisted at indexPath and indexes the blocks stored since,
+// rebuilding it from the whole store if it doesn't match the main chain.
+func openTxIndex(indexPath string, db BlockStore) (*txIndex, error) {
+	idx, err := loadTxIndex(indexPath)
+	if err == nil {
+		err = idx.matches(db)
+	}
+
+	if err != nil {
+		fmt.Printf("rebuilding TX index %s\n", indexPath)
+
+		idx = newTxIndex()
+		if err := idx.write(indexPath); err != nil {
+			return nil, err
+		}
+	}
+
+	idx.path = indexPath
+	idx.file, err = os.OpenFile(indexPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Iterate(uint64(len(idx.blocks)), func(blockFs BlockFS) error {
+		return idx.add(blockFs)
+	})
+	if err != nil {
+		idx.close()
+
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+func loadTxIndex(indexPath string) (*txIndex, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	idx := newTxIndex()
+
+	// entries of blocks with many TXs may not fit a scanner's buffer
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		var entry txIndexEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, err
+		}
+
+		if err := idx.register(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return idx, nil
+}
+
+// matches checks the last indexed block is still on the main chain.
+//
+// Entries are only appended on top of the main chain and truncated on reorganisations,
+// so if the last one is on the main chain, all of them are.
+func (idx *txIndex) matches(db BlockStore) error {
+	if len(idx.blocks) == 0 {
+		return nil
+	}
+
+	last := idx.blocks[len(idx.blocks)-1]
+
+	blockFs, err := db.GetByHeight(last.Number)
+	if errors.Is(err, ErrBlockNotFound) {
+		return fmt.Errorf("TX index is ahead of the main chain, block %d isn't stored", last.Number)
+	}
+	if err != nil {
+		return err
+	}
+
+	if blockFs.Key != last.Hash {
+		return fmt.Errorf("TX index block %d '%x' isn't on the main chain", last.Number, last.Hash)
+	}
+
+	return nil
+}
+
+// write persists all the index entries to the given path, replacing its content.
+func (idx *txIndex) write(indexPath string) error {
+	entries := make([]byte, 0)
+
+	for _, entry := range idx.blocks {
+		entryJson, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, append(entryJson, '\n')...)
+	}
+
+	return ioutil.WriteFile(indexPath, entries, 0600)
+}
+
+// register indexes the entry of the block right after the last indexed one.
+func (idx *txIndex) register(entry txIndexEntry) error {
+	if entry.Number != uint64(len(idx.blocks)) {
+		return fmt.Errorf("TX index expected block number '%d' not '%d'", len(idx.blocks), entry.Number)
+	}
+
+	for i, tx := range entry.TXs {
+		location := TxLocation{tx.Hash, entry.Hash, entry.Number, uint(i)}
+		idx.byHash[tx.Hash] = location
+
+		idx.byAccount[tx.From] = append(idx.byAccount[tx.From], location)
+		if tx.To != tx.From {
+			idx.byAccount[tx.To] = append(idx.byAccount[tx.To], location)
+		}
+	}
+
+	idx.blocks = append(idx.blocks, entry)
+
+	return nil
+}
+
+// add indexes and persists the TXs of the block appended to the main chain.
+//
+// The block is already stored, so failing to persist its entry only invalidates tx.idx, see invalidate.
+func (idx *txIndex) add(blockFs BlockFS) error {
+	entry := txIndexEntry{blockFs.Key, blockFs.Value.Header.Number, make([]txIndexEntryTx, 0, len(blockFs.Value.TXs))}
+
+	for _, tx := range blockFs.Value.TXs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return err
+		}
+
+		entry.TXs = append(entry.TXs, txIndexEntryTx{txHash, tx.From, tx.To})
+	}
+
+	if err := idx.register(entry); err != nil {
+		return err
+	}
+
+	if idx.file == nil {
+		return nil
+	}
+
+	entryJson, err := json.Marshal(entry)
+	if err == nil {
+		_, err = idx.file.Write(append(entryJson, '\n'))
+	}
+
+	if err != nil {
+		idx.invalidate(err)
+	}
+
+	return nil
+}
+
+// truncateFrom forgets the TXs of all blocks from the given height up, e.g. when the chain reorganises.
+//
+// The blocks are already removed from the store, so failing to persist the index only invalidates tx.idx.
+func (idx *txIndex) truncateFrom(height uint64) {
+	if height >= uint64(len(idx.blocks)) {
+		return
+	}
+
+	for _, entry := range idx.blocks[height:] {
+		for _, tx := range entry.TXs {
+			delete(idx.byHash, tx.Hash)
+		}
+	}
+
+	for account, locations := range idx.byAccount {
+		kept := len(locations)
+		for kept > 0 && locations[kept-1].BlockNumber >= height {
+			kept--
+		}
+
+		if kept == 0 {
+			delete(idx.byAccount, account)
+		} else {
+			idx.byAccount[account] = locations[:kept]
+		}
+	}
+
+	idx.blocks = idx.blocks[:height]
+
+	if idx.file == nil {
+		return
+	}
+
+	err := idx.file.Close()
+	idx.file = nil
+
+	if err == nil {
+		err = idx.write(idx.path)
+	}
+
+	if err == nil {
+		idx.file, err = os.OpenFile(idx.path, os.O_APPEND|os.O_WRONLY, 0600)
+	}
+
+	if err != nil {
+		idx.invalidate(err)
+	}
+}
+
+// invalidate stops persisting the index and removes tx.idx, so it's rebuilt from the blocks on the next start.
+// The index is still kept up to date in memory.
+func (idx *txIndex) invalidate(err error) {
+	fmt.Printf("warning: unable to persist the TX index %s, it's rebuilt on the next start: %s\n", idx.path, err)
+
+	if idx.file != nil {
+		idx.file.Close()
+		idx.file = nil
+	}
+
+	if err := os.Remove(idx.path); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("warning: unable to remove the TX index %s: %s\n", idx.path, err)
+	}
+}
+
+func (idx *txIndex) close() error {
+	if idx.file == nil {
+		return nil
+	}
+
+	return idx.file.Close()
+}
+
+// indexMainChain indexes the TXs of the main chain blocks just stored from the given height up, oldest first.
+//
+// The blocks are stored already, so it never fails the chain update: the index is rebuilt on the next start instead.
+func (s *State) indexMainChain(height uint64, blocks []BlockFS) {
+	s.txIndex.truncateFrom(height)
+
+	for _, blockFs := range blocks {
+		if err := s.txIndex.add(blockFs); err != nil {
+			s.txIndex.invalidate(err)
+
+			return
+		}
+	}
+}
+
+// GetTx returns the main chain TX with the given hash and its location.
+func (s *State) GetTx(txHash Hash) (SignedTx, TxLocation, error) {
+	location, ok := s.txIndex.byHash[txHash]
+	if !ok {
+		return SignedTx{}, TxLocation{}, fmt.Errorf("%w '%x'", ErrTxNotFound, txHash)
+	}
+
+	blockFs, err := s.db.GetByHash(location.BlockHash)
+	if err != nil {
+		return SignedTx{}, TxLocation{}, err
+	}
+
+	if location.Index >= uint(len(blockFs.Value.TXs)) {
+		return SignedTx{}, TxLocation{}, fmt.Errorf("TX index is out of sync. block '%x' has no TX %d", location.BlockHash, location.Index)
+	}
+
+	return blockFs.Value.TXs[location.Index], location, nil
+}
+
+// GetAccountTXs returns a page of the main chain TXs sent or received by the account, oldest first,
+// skipping the first from ones, and the count of all the account TXs. A zero limit returns all the remaining TXs.
+func (s *State) GetAccountTXs(account common.Address, from uint, limit uint) ([]TxLocation, uint) {
+	locations := s.txIndex.byAccount[account]
+	total := uint(len(locations))
+
+	if from >= total {
+		return []TxLocation{}, total
+	}
+
+	to := total
+	if limit != 0 && limit < total-from {
+		to = from + limit
+	}
+
+	page := make([]TxLocation, to-from)
+	copy(page, locations[from:to])
+
+	return page, total
+}
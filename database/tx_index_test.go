@@ -0,0 +1,192 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestState_TxIndex(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+	otherReceiver := NewAccount("0x09ee50f2f37fcba1845de6fe5c762e83e65e755c")
+
+	tx1 := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)
+	tx2 := signTestTx(t, NewBaseTx(sender, otherReceiver, big.NewInt(20), 2, ""), key)
+	block0Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx1, tx2}))
+	require.NoError(t, err)
+
+	tx3 := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(30), 3, ""), key)
+	block1Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx3}))
+	require.NoError(t, err)
+
+	tx2Hash, err := tx2.Hash()
+	require.NoError(t, err)
+
+	tx, location, err := s.GetTx(tx2Hash)
+	require.NoError(t, err)
+	assert.Equal(t, tx2, tx)
+	assert.Equal(t, TxLocation{tx2Hash, block0Hash, 0, 1}, location)
+
+	_, _, err = s.GetTx(Hash{1})
+	assert.True(t, errors.Is(err, ErrTxNotFound))
+
+	locations, total := s.GetAccountTXs(sender, 0, 0)
+	assert.Equal(t, uint(3), total)
+	require.Len(t, locations, 3)
+	assert.Equal(t, block1Hash, locations[2].BlockHash)
+
+	// paging through the account TXs, oldest first
+	locations, total = s.GetAccountTXs(receiver, 1, 1)
+	assert.Equal(t, uint(2), total)
+	require.Len(t, locations, 1)
+	assert.Equal(t, block1Hash, locations[0].BlockHash)
+
+	locations, _ = s.GetAccountTXs(receiver, 2, 1)
+	assert.Empty(t, locations)
+
+	// a reorg forgets the reverted TXs, and indexes the applied ones
+	sideTx := signTestTx(t, NewBaseTx(sender, otherReceiver, big.NewInt(40), 3, ""), key)
+	sideBlock1 := mineTestBlockOn(t, s, block0Hash, 1, sender, []SignedTx{sideTx})
+	update, err := s.ImportBlock(sideBlock1)
+	require.NoError(t, err)
+
+	update, err = s.ImportBlock(mineTestBlockOn(t, s, update.Hash, 2, sender, []SignedTx{}))
+	require.NoError(t, err)
+	require.True(t, update.IsReorg())
+
+	tx3Hash, err := tx3.Hash()
+	require.NoError(t, err)
+	_, _, err = s.GetTx(tx3Hash)
+	assert.True(t, errors.Is(err, ErrTxNotFound))
+
+	_, total = s.GetAccountTXs(receiver, 0, 0)
+	assert.Equal(t, uint(1), total)
+
+	sideTxHash, err := sideTx.Hash()
+	require.NoError(t, err)
+	_, location, err = s.GetTx(sideTxHash)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), location.BlockNumber)
+
+	// the index is loaded from tx.idx on restart
+	require.NoError(t, s.Close())
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	_, restartedLocation, err := restarted.GetTx(sideTxHash)
+	require.NoError(t, err)
+	assert.Equal(t, location, restartedLocation)
+
+	locations, total = restarted.GetAccountTXs(otherReceiver, 0, 0)
+	assert.Equal(t, uint(2), total)
+	assert.Len(t, locations, 2)
+}
+
+func TestState_TxIndexIsPersisted(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	tx1 := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)
+	block0Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx1}))
+	require.NoError(t, err)
+
+	tx2 := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(20), 2, ""), key)
+	block1Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx2}))
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	indexPath := getTxIndexFilePath(s.dataDir)
+	idx, err := loadTxIndex(indexPath)
+	require.NoError(t, err)
+	require.Len(t, idx.blocks, 2)
+	assert.Equal(t, block1Hash, idx.blocks[1].Hash)
+
+	tx2Hash, err := tx2.Hash()
+	require.NoError(t, err)
+
+	// a block stored without being indexed, e.g. on a crash, is indexed on the next start
+	idx.blocks = idx.blocks[:1]
+	require.NoError(t, idx.write(indexPath))
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+
+	_, location, err := restarted.GetTx(tx2Hash)
+	require.NoError(t, err)
+	assert.Equal(t, TxLocation{tx2Hash, block1Hash, 1, 0}, location)
+	require.NoError(t, restarted.Close())
+
+	// an index not matching the main chain is rebuilt
+	idx, err = loadTxIndex(indexPath)
+	require.NoError(t, err)
+	idx.blocks[1].Hash = block0Hash
+	idx.blocks[1].TXs = nil
+	require.NoError(t, idx.write(indexPath))
+
+	restarted, err = NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	_, location, err = restarted.GetTx(tx2Hash)
+	require.NoError(t, err)
+	assert.Equal(t, block1Hash, location.BlockHash)
+
+	_, total := restarted.GetAccountTXs(receiver, 0, 0)
+	assert.Equal(t, uint(2), total)
+}
+
+func TestState_ReorganisationSurvivesTxIndexFailure(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	tx1 := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(10), 1, ""), key)
+	block0Hash, err := s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{tx1}))
+	require.NoError(t, err)
+
+	_, err = s.AddBlock(mineTestBlock(t, s, sender, []SignedTx{}))
+	require.NoError(t, err)
+
+	// tx.idx can't be written anymore
+	require.NoError(t, s.txIndex.file.Close())
+
+	sideTx := signTestTx(t, NewBaseTx(sender, receiver, big.NewInt(20), 2, ""), key)
+	update, err := s.ImportBlock(mineTestBlockOn(t, s, block0Hash, 1, sender, []SignedTx{sideTx}))
+	require.NoError(t, err)
+
+	update, err = s.ImportBlock(mineTestBlockOn(t, s, update.Hash, 2, sender, []SignedTx{}))
+	require.NoError(t, err)
+	require.True(t, update.IsReorg())
+
+	// the state follows the stored chain, the index is kept in memory and rebuilt on restart
+	assert.Equal(t, update.Hash, s.LatestBlockHash())
+	assert.False(t, utils.FileExist(getTxIndexFilePath(s.dataDir)))
+
+	sideTxHash, err := sideTx.Hash()
+	require.NoError(t, err)
+	_, location, err := s.GetTx(sideTxHash)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), location.BlockNumber)
+
+	require.NoError(t, s.Close())
+
+	restarted, err := NewStateFromDisk(s.dataDir, testMiningDifficulty)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	_, restartedLocation, err := restarted.GetTx(sideTxHash)
+	require.NoError(t, err)
+	assert.Equal(t, location, restartedLocation)
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// From the TIP-7 fork on, a block TXs are applied in the order the miner put them in, which the TX root commits to.
+// The only rule is that the TXs of a sender come in increasing nonce order, each one following the previous one.
+
+// OrderTXs returns the TXs ordered by time, each sender's TXs being ordered by nonce.
+//
+// Ties on time are broken by sender and nonce, so the order doesn't depend on the order the TXs were received in.
+// A sender's TX with a lower nonce but a later time takes the place of the earliest of the sender's TXs,
+// rather than breaking the nonce order.
+func OrderTXs(txs []SignedTx) []SignedTx {
+	ordered := make([]SignedTx, len(txs))
+	copy(ordered, txs)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Time != ordered[j].Time {
+			return ordered[i].Time < ordered[j].Time
+		}
+
+		if ordered[i].From != ordered[j].From {
+			return ordered[i].From.Hex() < ordered[j].From.Hex()
+		}
+
+		return ordered[i].Nonce < ordered[j].Nonce
+	})
+
+	// reassign each sender's slots, in time order, to the sender's TXs in nonce order
+	bySender := make(map[common.Address][]SignedTx)
+	for _, tx := range ordered {
+		bySender[tx.From] = append(bySender[tx.From], tx)
+	}
+
+	for _, senderTXs := range bySender {
+		sort.SliceStable(senderTXs, func(i, j int) bool {
+			return senderTXs[i].Nonce < senderTXs[j].Nonce
+		})
+	}
+
+	for i, tx := range ordered {
+		senderTXs := bySender[tx.From]
+		ordered[i] = senderTXs[0]
+		bySender[tx.From] = senderTXs[1:]
+	}
+
+	return ordered
+}
+
+// validateTxOrder checks the TXs of each sender come in increasing nonce order.
+// Gaps between nonces are reported when the TXs are applied, as the next expected nonce depends on the state.
+func validateTxOrder(txs []SignedTx) error {
+	lastNonces := make(map[common.Address]uint)
+
+	for i, tx := range txs {
+		lastNonce, ok := lastNonces[tx.From]
+		if ok && tx.Nonce <= lastNonce {
+			return fmt.Errorf("%w. sender '%s' TX no. %d with nonce '%d' must come before its TX with nonce '%d'", ErrInvalidTxOrder, tx.From.String(), i, tx.Nonce, lastNonce)
+		}
+
+		lastNonces[tx.From] = tx.Nonce
+	}
+
+	return nil
+}
+
+// sortTXsByTime returns the TXs in the order they were applied in prior to the TIP-7 fork, leaving the block TXs untouched.
+//
+// It's a consensus rule of the existing chains: the unstable sort.Slice must stay, the order of TXs with equal times
+// can differ from a stable sort's once a block has more than 12 TXs.
+func sortTXsByTime(txs []SignedTx) []SignedTx {
+	sorted := make([]SignedTx, len(txs))
+	copy(sorted, txs)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Time < sorted[j].Time
+	})
+
+	return sorted
+}
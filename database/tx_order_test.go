@@ -0,0 +1,119 @@
+package database
+
+import (
+	"math/big"
+	"sort"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestOrderTXs(t *testing.T) {
+	andrej := NewAccount("0x22ba1f80452e6220c7cc6ea2d1e3eeddac5f694a")
+	babaYaga := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	newTx := func(from common.Address, nonce uint, time uint64) SignedTx {
+		tx := NewBaseTx(from, from, big.NewInt(1), nonce, "")
+		tx.Time = time
+
+		return NewSignedTx(tx, []byte{})
+	}
+
+	testCases := map[string]struct {
+		txs       []SignedTx
+		wantOrder []SignedTx
+	}{
+		"by time": {
+			txs:       []SignedTx{newTx(babaYaga, 1, 20), newTx(andrej, 1, 10)},
+			wantOrder: []SignedTx{newTx(andrej, 1, 10), newTx(babaYaga, 1, 20)},
+		},
+		"same time by sender": {
+			txs:       []SignedTx{newTx(babaYaga, 1, 10), newTx(andrej, 1, 10)},
+			wantOrder: []SignedTx{newTx(andrej, 1, 10), newTx(babaYaga, 1, 10)},
+		},
+		"sender nonces before time": {
+			txs:       []SignedTx{newTx(andrej, 2, 10), newTx(babaYaga, 1, 20), newTx(andrej, 1, 30)},
+			wantOrder: []SignedTx{newTx(andrej, 1, 30), newTx(babaYaga, 1, 20), newTx(andrej, 2, 10)},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			txs := append([]SignedTx{}, tc.txs...)
+
+			assert.Equal(t, tc.wantOrder, OrderTXs(txs))
+			assert.Equal(t, tc.txs, txs, "the TXs must not be reordered in place")
+			assert.NoError(t, validateTxOrder(OrderTXs(txs)))
+		})
+	}
+}
+
+func TestSortTXsByTime(t *testing.T) {
+	sender := NewAccount("0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57")
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	// More TXs sharing a time than sort.Slice orders by insertion sort, where it's no longer stable
+	txs := make([]SignedTx, 0)
+	for i := uint(0); i < 20; i++ {
+		tx := NewBaseTx(sender, receiver, amount(i), i+1, "")
+		tx.Time = uint64(1000 + i%3)
+		txs = append(txs, SignedTx{Tx: tx})
+	}
+
+	// The order of the nodes prior to the TIP-7 fork, sorting the TXs with sort.Slice
+	expected := make([]SignedTx, len(txs))
+	copy(expected, txs)
+	sort.Slice(expected, func(i, j int) bool {
+		return expected[i].Time < expected[j].Time
+	})
+
+	assert.Equal(t, expected, sortTXsByTime(txs))
+	assert.Equal(t, "0", txs[0].Value.String(), "the block TXs must be left untouched")
+}
+
+func TestState_TxOrderFork(t *testing.T) {
+	testCases := map[string]struct {
+		forkTIP7 *uint64
+		// nonces of the block TXs, in the block order, each TX is a second older than the previous one
+		nonces  []uint
+		wantErr string
+	}{
+		"legacy TXs applied by time":   {forkTIP7: nil, nonces: []uint{2, 1}},
+		"legacy TXs out of time order": {forkTIP7: nil, nonces: []uint{1, 2}, wantErr: "next nonce must be '1', not '2'"},
+		"block order preserved":        {forkTIP7: new(uint64), nonces: []uint{1, 2, 3}},
+		"sender nonces out of order":   {forkTIP7: new(uint64), nonces: []uint{2, 1}, wantErr: "wrong TXs order"},
+		"sender nonce used twice":      {forkTIP7: new(uint64), nonces: []uint{1, 1}, wantErr: "wrong TXs order"},
+		"gap between sender nonces":    {forkTIP7: new(uint64), nonces: []uint{1, 3}, wantErr: "next nonce must be '2', not '3'"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s, key, sender := newTestState(t, Genesis{ForkTIP1: 0, ForkTIP7: tc.forkTIP7})
+			defer utils.RemoveDir(s.dataDir)
+			defer s.Close()
+
+			txs := make([]SignedTx, len(tc.nonces))
+			for i, nonce := range tc.nonces {
+				tx := NewBaseTx(sender, NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8"), big.NewInt(10), nonce, "")
+				tx.Time -= uint64(i)
+				txs[i] = signTestTx(t, tx, key)
+			}
+
+			block := mineTestBlock(t, s, sender, txs)
+			blockTXs := append([]SignedTx{}, block.TXs...)
+
+			_, err := s.AddBlock(block)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, blockTXs, block.TXs, "the block TXs must not be reordered")
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestSignedTx_AmountsKeepEncoding(t *testing.T) {
+	testCases := map[string]string{
+		"prior to TIP-1": `{"from":"0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57","to":"0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8","value":100,"nonce":1,"data":"","time":1579451695,"signature":"AQI="}`,
+		"TIP-1":          `{"from":"0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57","to":"0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8","gas":21,"gasPrice":1,"value":100,"nonce":1,"data":"","time":1579451695,"signature":"AQI="}`,
+		"beyond uint64":  `{"from":"0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57","to":"0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8","gas":21,"gasPrice":1,"value":340282366920938463463374607431768211456,"nonce":1,"data":"","time":1579451695,"signature":"AQI="}`,
+	}
+
+	for name, txJson := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var tx SignedTx
+			require.NoError(t, json.Unmarshal([]byte(txJson), &tx))
+
+			encoded, err := json.Marshal(tx)
+			require.NoError(t, err)
+			assert.Equal(t, txJson, string(encoded))
+		})
+	}
+}
+
+func TestState_RejectsNegativeTxAmounts(t *testing.T) {
+	s, key, sender := newTestState(t, Genesis{ForkTIP1: 0})
+	defer utils.RemoveDir(s.dataDir)
+	defer s.Close()
+
+	receiver := NewAccount("0x6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8")
+
+	negativeValue := NewBaseTx(sender, receiver, big.NewInt(-100), 1, "")
+	err := s.ValidateTx(signTestTx(t, negativeValue, key))
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+
+	negativeGasPrice := NewTx(sender, receiver, big.NewInt(100), 1, amount(TxGas), big.NewInt(-1), "")
+	err = s.ValidateTx(signTestTx(t, negativeGasPrice, key))
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+
+	// Values beyond the native uint width are accounted for in full
+	huge, ok := new(big.Int).SetString("340282366920938463463374607431768211456", 10)
+	require.True(t, ok)
+	err = s.ValidateTx(signTestTx(t, NewBaseTx(sender, receiver, huge, 1, ""), key))
+	assert.True(t, errors.Is(err, ErrInsufficientBalance))
+}
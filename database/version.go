@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"the-blockchain-bar/utils"
+)
+
+// DataDirVersion is the layout version of the database dir this node reads and writes.
+//
+// Bump it, together with a new migration, whenever the persisted format changes.
+const DataDirVersion = 1
+
+var ErrDataDirTooNew = errors.New("data dir layout is newer than this node")
+
+// migration upgrades the database dir layout from one version to the next.
+type migration struct {
+	description string
+	migrate     func(dataDir string) error
+}
+
+// migrations[i] upgrades a data dir of version i to version i+1.
+var migrations = []migration{
+	{
+		description: "checksum block records written prior to checksums",
+		migrate:     checksumLegacyBlockRecords,
+	},
+}
+
+// ReadDataDirVersion returns the layout version of the data dir.
+// Data dirs created before the layout was versioned have no VERSION file and are version 0.
+func ReadDataDirVersion(dataDir string) (uint, error) {
+	content, err := ioutil.ReadFile(getVersionFilePath(dataDir))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("malformed data dir VERSION file. %s", err.Error())
+	}
+
+	return uint(version), nil
+}
+
+// MigrateDataDir upgrades the data dir layout to DataDirVersion, one version at a time.
+//
+// The VERSION file is rewritten after every step, so an interrupted upgrade resumes from the last completed one.
+// A data dir newer than DataDirVersion was written by a newer node and is refused with ErrDataDirTooNew.
+func MigrateDataDir(dataDir string) error {
+	version, err := ReadDataDirVersion(dataDir)
+	if err != nil {
+		return err
+	}
+
+	if version > DataDirVersion {
+		return fmt.Errorf("%w. data dir is version %d, this node supports up to %d", ErrDataDirTooNew, version, DataDirVersion)
+	}
+
+	for ; version < DataDirVersion; version++ {
+		m := migrations[version]
+		fmt.Printf("Upgrading data dir from version %d to %d: %s...\n", version, version+1, m.description)
+
+		if err := m.migrate(dataDir); err != nil {
+			return fmt.Errorf("upgrading data dir from version %d to %d failed. %s", version, version+1, err.Error())
+		}
+
+		if err := writeDataDirVersion(dataDir, version+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDataDirVersion persists the version atomically, through a temporary file renamed over the previous one.
+func writeDataDirVersion(dataDir string, version uint) error {
+	return writeFileAtomic(getVersionFilePath(dataDir), []byte(fmt.Sprintf("%d\n", version)))
+}
+
+// checksumLegacyBlockRecords rewrites the file backend blocks, so every record carries its checksum.
+//
+// The rewritten file is built aside and only swapped in once complete, the previous one is kept with a ".bak" suffix.
+// The LevelDB backend was introduced together with checksums, so it has nothing to upgrade.
+func checksumLegacyBlockRecords(dataDir string) error {
+	if DetectBlockStoreBackend(dataDir) != BackendFile {
+		return nil
+	}
+
+	path := getBlocksDbFilePath(dataDir)
+	tmpPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".upgrading" + filepath.Ext(path)
+
+	src, err := openFileBlockStore(path)
+	if err != nil {
+		return err
+	}
+
+	dst, err := newBlockStoreAt(tmpPath, BackendFile)
+	if err != nil {
+		src.Close()
+
+		return err
+	}
+
+	err = src.Iterate(0, func(blockFs BlockFS) error {
+		return dst.Append(blockFs)
+	})
+
+	src.Close()
+	dst.Close()
+
+	if err != nil {
+		return err
+	}
+
+	// the index is derived from block.db and gets rebuilt on the next open
+	if err := os.RemoveAll(getBlocksIndexFilePath(path)); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(getBlocksIndexFilePath(tmpPath)); err != nil {
+		return err
+	}
+
+	if err := utils.RemoveDir(path + ".bak"); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path, path+".bak"); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func getVersionFilePath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "VERSION")
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/test-go/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestInitDataDir_WritesVersion(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_version_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	require.NoError(t, InitDataDirIfNotExists(dataDir, []byte(genesisJson)))
+
+	version, err := ReadDataDirVersion(dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, uint(DataDirVersion), version)
+}
+
+func TestMigrateDataDir_UpgradesLegacyLayout(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_version_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	// A data dir predating the VERSION file, with blocks written prior to checksums
+	require.NoError(t, InitDataDirIfNotExists(dataDir, []byte(genesisJson)))
+	require.NoError(t, os.Remove(getVersionFilePath(dataDir)))
+
+	blocks := make([]BlockFS, 0)
+	legacyRecords := make([]byte, 0)
+	parent := Hash{}
+	for i := uint64(0); i < 3; i++ {
+		block := NewBlock(parent, i, 0, i, NewAccount(""), []SignedTx{})
+		hash, err := block.Hash()
+		require.NoError(t, err)
+
+		blockFsJson, err := json.Marshal(BlockFS{Key: hash, Value: block})
+		require.NoError(t, err)
+
+		legacyRecords = append(append(legacyRecords, blockFsJson...), '\n')
+		blocks = append(blocks, BlockFS{Key: hash, Value: block})
+		parent = hash
+	}
+
+	dbPath := getBlocksDbFilePath(dataDir)
+	require.NoError(t, ioutil.WriteFile(dbPath, legacyRecords, 0600))
+
+	require.NoError(t, InitDataDirIfNotExists(dataDir, []byte(genesisJson)))
+
+	version, err := ReadDataDirVersion(dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, uint(DataDirVersion), version)
+
+	upgraded, err := ioutil.ReadFile(dbPath)
+	require.NoError(t, err)
+	for _, record := range bytes.Split(bytes.TrimSuffix(upgraded, []byte{'\n'}), []byte{'\n'}) {
+		assert.NotEqual(t, byte('{'), record[0], "block record must carry its checksum")
+	}
+
+	store, err := openBlockStore(dataDir, BackendFile)
+	require.NoError(t, err)
+	assertStoreContains(t, store, blocks)
+	require.NoError(t, store.Close())
+}
+
+func TestMigrateDataDir_RefusesNewerLayout(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "tbb_version_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	require.NoError(t, InitDataDirIfNotExists(dataDir, []byte(genesisJson)))
+	require.NoError(t, writeDataDirVersion(dataDir, DataDirVersion+1))
+
+	err = InitDataDirIfNotExists(dataDir, []byte(genesisJson))
+	assert.True(t, errors.Is(err, ErrDataDirTooNew))
+
+	_, err = NewStateFromDisk(dataDir, 1)
+	assert.True(t, errors.Is(err, ErrDataDirTooNew))
+}
@@ -13,11 +13,17 @@ import (
 )
 
 type PendingBlock struct {
-	parent database.Hash
-	number uint64
-	time   uint64
-	miner  common.Address
-	txs    []database.SignedTx
+	parent    database.Hash
+	number    uint64
+	time      uint64
+	miner     common.Address
+	txs       []database.SignedTx
+	txRoot    *database.Hash
+	stateRoot *database.Hash
+	// difficulty recorded in the header, from the TIP-4 fork until TIP-5
+	difficulty uint
+	// compact target replacing the difficulty, from the TIP-5 fork on
+	bits uint32
 }
 
 func NewPendingBlock(parent database.Hash, number uint64, miner common.Address, txs []database.SignedTx) PendingBlock {
@@ -30,6 +36,55 @@ func NewPendingBlock(parent database.Hash, number uint64, miner common.Address,
 	}
 }
 
+// WithTime stamps the block with the given time instead of the current one.
+func (pb PendingBlock) WithTime(time uint64) PendingBlock {
+	pb.time = time
+
+	return pb
+}
+
+// WithTxRoot makes the mined block header commit to the TXs through their Merkle root, as required from the TIP-2 fork on.
+func (pb PendingBlock) WithTxRoot() (PendingBlock, error) {
+	txRoot, err := database.TxRoot(pb.txs)
+	if err != nil {
+		return pb, err
+	}
+
+	pb.txRoot = &txRoot
+
+	return pb, nil
+}
+
+// WithStateRoot makes the mined block header commit to the state after the block, as required from the TIP-3 fork on.
+func (pb PendingBlock) WithStateRoot(stateRoot database.Hash) PendingBlock {
+	pb.stateRoot = &stateRoot
+
+	return pb
+}
+
+// WithDifficulty records the difficulty in the mined block header, as required from the TIP-4 fork on.
+func (pb PendingBlock) WithDifficulty(difficulty uint) PendingBlock {
+	pb.difficulty = difficulty
+
+	return pb
+}
+
+// WithBits makes the block hash have to be lower than the compact target, instead of satisfying the mining difficulty,
+// as required from the TIP-5 fork on.
+func (pb PendingBlock) WithBits(bits uint32) PendingBlock {
+	pb.bits = bits
+
+	return pb
+}
+
+func (pb PendingBlock) isHashValid(hash database.Hash, miningDifficulty uint) bool {
+	if pb.bits != 0 {
+		return database.IsBlockHashBelowTarget(hash, pb.bits)
+	}
+
+	return database.IsBlockHashValid(hash, miningDifficulty)
+}
+
 func Mine(ctx context.Context, pb PendingBlock, miningDifficulty uint) (database.Block, error) {
 	if len(pb.txs) == 0 {
 		return database.Block{}, errors.New("mining empty blocks is not allowed")
@@ -41,7 +96,7 @@ func Mine(ctx context.Context, pb PendingBlock, miningDifficulty uint) (database
 	var hash database.Hash
 	var nonce uint32
 
-	for !database.IsBlockHashValid(hash, miningDifficulty) {
+	for attempt == 0 || !pb.isHashValid(hash, miningDifficulty) {
 		select {
 		case <-ctx.Done():
 			fmt.Println("mining cancelled")
@@ -58,6 +113,10 @@ func Mine(ctx context.Context, pb PendingBlock, miningDifficulty uint) (database
 		}
 
 		block = database.NewBlock(pb.parent, pb.number, nonce, pb.time, pb.miner, pb.txs)
+		block.Header.TxRoot = pb.txRoot
+		block.Header.StateRoot = pb.stateRoot
+		block.Header.Difficulty = pb.difficulty
+		block.Header.Bits = pb.bits
 		blockHash, err := block.Hash()
 		if err != nil {
 			return database.Block{}, fmt.Errorf("could not mine block: %s", err.Error())
@@ -6,6 +6,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"encoding/hex"
+	"math/big"
 	"testing"
 	"the-blockchain-bar/database"
 	"the-blockchain-bar/resources"
@@ -69,6 +70,27 @@ func TestMine(t *testing.T) {
 	}
 }
 
+func TestMineWithBits(t *testing.T) {
+	minerPrivateKey, _, miner, err := generateKey()
+	assert.NoError(t, err)
+
+	pendingBlock, err := createRandomPendingBlock(minerPrivateKey, miner)
+	assert.NoError(t, err)
+
+	// half the target of difficulty 1, finer than the legacy difficulty allows
+	bits := database.TargetToCompact(new(big.Int).Rsh(database.CompactToTarget(database.DifficultyToBits(1)), 1))
+
+	minedBlock, err := Mine(context.Background(), pendingBlock.WithBits(bits), defaultTestMiningDifficulty)
+	assert.NoError(t, err)
+
+	minedBlockHash, err := minedBlock.Hash()
+	assert.NoError(t, err)
+
+	assert.Equal(t, bits, minedBlock.Header.Bits)
+	assert.True(t, database.IsBlockHashBelowTarget(minedBlockHash, bits))
+	assert.True(t, minedBlockHash[0] == 0 && minedBlockHash[1] < 0x80, "hash %x", minedBlockHash)
+}
+
 func TestMineWithTimeout(t *testing.T) {
 	minerPrivateKey, _, miner, err := generateKey()
 	assert.NoError(t, err)
@@ -84,7 +106,8 @@ func TestMineWithTimeout(t *testing.T) {
 }
 
 func createRandomPendingBlock(privateKey *ecdsa.PrivateKey, minerAccount common.Address) (PendingBlock, error) {
-	tx := database.NewBaseTx(minerAccount, database.NewAccount(resources.TestKsBabaYagaAccount), 1, 1, "")
+	tx := database.NewBaseTx(minerAccount, database.NewAccount(resources.TestKsBabaYagaAccount), big.NewInt(1), 1, "")
+	tx.ChainID = "the-blockchain-bar-test"
 	signedTx, err := wallet.SignTx(tx, privateKey)
 	if err != nil {
 		return PendingBlock{}, err
@@ -1,6 +1,7 @@
 package node
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,10 +11,47 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
-func listBalancesHandler(w http.ResponseWriter, _ *http.Request, state *database.State) {
+func listBalancesHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	blockRaw := r.URL.Query().Get(endpointBalancesQueryKeyBlock)
+	if blockRaw == "" {
+		writeSuccessfulResponse(w, balancesResponse{
+			Hash:     state.LatestBlockHash(),
+			Number:   state.LatestBlock().Header.Number,
+			Balances: state.Balances,
+		})
+
+		return
+	}
+
+	// the block is given by its height or its hash
+	height, err := strconv.ParseUint(blockRaw, 10, 64)
+	if err != nil {
+		blockHash := database.Hash{}
+		if err := blockHash.UnmarshalText([]byte(blockRaw)); err != nil {
+			writeErrorResponse(w, fmt.Errorf("%w. block must be a height or a hash. %s", errInvalidRequest, err))
+
+			return
+		}
+
+		height, err = state.BlockHeight(blockHash)
+		if err != nil {
+			writeErrorResponse(w, err)
+
+			return
+		}
+	}
+
+	balances, blockHash, err := state.BalancesAt(height)
+	if err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
 	writeSuccessfulResponse(w, balancesResponse{
-		Hash:     state.LatestBlockHash(),
-		Balances: state.Balances,
+		Hash:     blockHash,
+		Number:   height,
+		Balances: balances,
 	})
 }
 
@@ -29,29 +67,57 @@ func txAddHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 	to := database.NewAccount(req.To)
 
 	if from.String() == common.HexToAddress("").String() {
-		writeErrorResponse(w, fmt.Errorf("%s is an invalid 'from' sender", from.String()))
+		writeErrorResponse(w, fmt.Errorf("%w. %s is an invalid 'from' sender", errInvalidRequest, from.String()))
 
 		return
 	}
 
 	if req.KeystorePassword == "" {
-		writeErrorResponse(w, fmt.Errorf("password to decrypt the %s account is required. 'pwd' is empty", from.String()))
+		writeErrorResponse(w, fmt.Errorf("%w. password to decrypt the %s account is required. 'pwd' is empty", errInvalidRequest, from.String()))
+
+		return
+	}
+
+	// Build the unsigned transaction, following the sender's pending TXs
+	nonce, err := node.state.GetNextNonceAfterPending(from, node.getPendingTXsAsArray())
+	if err != nil {
+		writeErrorResponse(w, err)
 
 		return
 	}
 
-	// Build the unsigned transaction
-	nonce := node.state.GetNextNonceByAccount(from)
 	tx := database.NewTx(from, to, req.Value, nonce, req.Gas, req.GasPrice, req.Data)
 
-	// Decrypt the Private key stored in Keystore file and Sign the TX
-	signedTx, err := wallet.SignTxWithKeystoreAccount(tx, from, req.KeystorePassword, wallet.GetKeystoreDirPath(node.dataDir))
+	// Decrypt the Private key stored in Keystore file
+	key, err := wallet.DecryptKeystoreAccount(from, req.KeystorePassword, wallet.GetKeystoreDirPath(node.dataDir))
 	if err != nil {
 		writeErrorResponse(w, err)
 
 		return
 	}
 
+	// Sign the TX for this network from the TIP-8 fork on
+	var signedTx database.SignedTx
+	if node.state.IsTIP8Fork() {
+		tx.ChainID = node.state.ChainID()
+		signedTx, err = wallet.SignTx(tx, key.PrivateKey)
+	} else {
+		signedTx, err = wallet.SignLegacyTx(tx, key.PrivateKey)
+	}
+
+	if err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	// Reject a TX the next blocks couldn't include after the sender's pending TXs, e.g. for an insufficient balance, with the reason
+	if err := node.state.ValidatePendingTx(signedTx, node.getPendingTXsAsArray()); err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
 	// Add TX to the MemPool, ready to be mined
 	if err := node.AddPendingTX(signedTx, node.info); err != nil {
 		writeErrorResponse(w, err)
@@ -62,10 +128,188 @@ func txAddHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 	writeSuccessfulResponse(w, txAddResponse{Success: true})
 }
 
+// txSendRawHandler adds a TX signed by the client, e.g. with `tbb tx sign`, so the sender's key never leaves their machine.
+func txSendRawHandler(w http.ResponseWriter, r *http.Request, node *Node) {
+	signedTx := database.SignedTx{}
+	if err := requestFromBody(r, &signedTx); err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	isAuthentic, err := signedTx.IsAuthentic()
+	if errors.Is(err, database.ErrInvalidMultisig) {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	if err != nil {
+		writeErrorResponse(w, &database.ForgedSignatureError{Account: signedTx.From, Err: err})
+
+		return
+	}
+
+	if !isAuthentic {
+		writeErrorResponse(w, &database.ForgedSignatureError{Account: signedTx.From})
+
+		return
+	}
+
+	// Reject a TX the next blocks couldn't include after the sender's pending TXs, e.g. for an insufficient balance, with the reason
+	if err := node.state.ValidatePendingTx(signedTx, node.getPendingTXsAsArray()); err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	txHash, err := signedTx.Hash()
+	if err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	if err := node.AddPendingTX(signedTx, node.info); err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	writeSuccessfulResponse(w, txSendRawResponse{Success: true, Hash: txHash})
+}
+
+func txProofHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	txHash := database.Hash{}
+	if err := txHash.UnmarshalText([]byte(r.URL.Query().Get(endpointTxProofQueryKeyHash))); err != nil {
+		writeErrorResponse(w, fmt.Errorf("%w. %s", errInvalidRequest, err))
+
+		return
+	}
+
+	blockFs, proof, err := state.GetTxProof(txHash)
+	if err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	// The header is all a light client needs to check the block hash, and the proof against the header TX root
+	writeSuccessfulResponse(w, txProofResponse{
+		BlockHash:   blockFs.Key,
+		BlockHeader: blockFs.Value.Header,
+		Proof:       proof,
+	})
+}
+
+func txGetHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	txHash := database.Hash{}
+	if err := txHash.UnmarshalText([]byte(r.URL.Query().Get(endpointTxGetQueryKeyHash))); err != nil {
+		writeErrorResponse(w, fmt.Errorf("%w. %s", errInvalidRequest, err))
+
+		return
+	}
+
+	tx, location, err := state.GetTx(txHash)
+	if err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	writeSuccessfulResponse(w, txGetResponse{Tx: tx, TxLocation: location})
+}
+
+func txReceiptHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	txHash := database.Hash{}
+	if err := txHash.UnmarshalText([]byte(r.URL.Query().Get(endpointTxReceiptQueryKeyHash))); err != nil {
+		writeErrorResponse(w, fmt.Errorf("%w. %s", errInvalidRequest, err))
+
+		return
+	}
+
+	receipt, err := state.GetReceipt(txHash)
+	if err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	writeSuccessfulResponse(w, receipt)
+}
+
+// accountTXsHandler pages through the TXs sent or received by the account, oldest first.
+func accountTXsHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	query := r.URL.Query()
+
+	address := query.Get(endpointAccountTXsQueryKeyAddress)
+	if !common.IsHexAddress(address) {
+		writeErrorResponse(w, fmt.Errorf("%w. '%s' is not an account address", errInvalidRequest, address))
+
+		return
+	}
+
+	from, err := parseUintQueryParam(query.Get(endpointAccountTXsQueryKeyFrom), 0)
+	if err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	limit, err := parseUintQueryParam(query.Get(endpointAccountTXsQueryKeyLimit), accountTXsMaxLimit)
+	if err != nil {
+		writeErrorResponse(w, err)
+
+		return
+	}
+
+	if limit == 0 || limit > accountTXsMaxLimit {
+		limit = accountTXsMaxLimit
+	}
+
+	account := database.NewAccount(address)
+	locations, total := state.GetAccountTXs(account, uint(from), uint(limit))
+
+	txs := make([]txGetResponse, 0, len(locations))
+	for _, location := range locations {
+		tx, _, err := state.GetTx(location.TxHash)
+		if err != nil {
+			writeErrorResponse(w, err)
+
+			return
+		}
+
+		txs = append(txs, txGetResponse{Tx: tx, TxLocation: location})
+	}
+
+	writeSuccessfulResponse(w, accountTXsResponse{
+		Account: account,
+		Total:   total,
+		From:    uint(from),
+		TXs:     txs,
+	})
+}
+
+// chainSupplyHandler reports the coins in circulation after the latest block and the emission schedule.
+func chainSupplyHandler(w http.ResponseWriter, _ *http.Request, state *database.State) {
+	policy := state.RewardPolicy()
+
+	writeSuccessfulResponse(w, chainSupplyResponse{
+		Hash:            state.LatestBlockHash(),
+		Number:          state.LatestBlock().Header.Number,
+		Supply:          state.Supply(),
+		GenesisSupply:   policy.GenesisSupply,
+		MaxSupply:       policy.MaxSupply,
+		NextBlockReward: state.NextBlockReward(),
+		HalvingInterval: policy.HalvingInterval,
+	})
+}
+
 func statusHandler(w http.ResponseWriter, _ *http.Request, n *Node) {
 	res := statusResponse{
 		Hash:        n.state.LatestBlockHash(),
 		Number:      n.state.LatestBlock().Header.Number,
+		TotalWork:   n.state.TotalWork(),
 		KnownPeers:  n.knownPeers,
 		PendingTXs:  n.getPendingTXsAsArray(),
 		NodeVersion: n.nodeVersion,
@@ -81,13 +325,13 @@ func syncHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 
 	hash := database.Hash{}
 	if err := hash.UnmarshalText([]byte(reqHash)); err != nil {
-		writeErrorResponse(w, err)
+		writeErrorResponse(w, fmt.Errorf("%w. %s", errInvalidRequest, err))
 
 		return
 	}
 
-	// read newer blocks from db
-	blocks, err := database.GetBlocksAfter(hash, node.dataDir)
+	// read newer blocks from db, seeking them through the blocks index
+	blocks, err := node.state.GetBlocksAfter(hash)
 	if err != nil {
 		writeErrorResponse(w, err)
 
@@ -120,3 +364,17 @@ func addPeerHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 
 	writeSuccessfulResponse(w, addPeerResponse{true, ""})
 }
+
+// parseUintQueryParam parses an optional numeric query parameter, falling back to the default if it's missing.
+func parseUintQueryParam(value string, defaultValue uint64) (uint64, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	number, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w. %s", errInvalidRequest, err)
+	}
+
+	return number, nil
+}
@@ -44,24 +44,73 @@ func (n *Node) mine(ctx context.Context) {
 }
 
 func (n *Node) minePendingTXs(ctx context.Context) error {
+	// From the TIP-9 fork on, the highest-paying TXs fitting the block limits are mined first, the others wait
+	pendingTXs, err := n.state.NextBlockLimits().SelectTXs(n.getPendingTXsAsArray())
+	if err != nil {
+		return err
+	}
+
+	// From the TIP-10 fork on, the block starts with the coinbase paying this node the reward and the fees
+	if n.state.IsTIP10Fork() {
+		coinbase, err := n.state.NewCoinbaseTx(n.info.Account, pendingTXs)
+		if err != nil {
+			return err
+		}
+
+		pendingTXs = append([]database.SignedTx{coinbase}, pendingTXs...)
+	}
+
 	blockToMine := miner.NewPendingBlock(
 		n.state.LatestBlockHash(),
 		n.state.NextBlockNumber(),
 		n.info.Account,
-		n.getPendingTXsAsArray(),
+		pendingTXs,
 	)
 
-	minedBlock, err := miner.Mine(ctx, blockToMine, n.miningDifficulty)
+	// From the TIP-6 fork on, the block time must be later than the median time of the last blocks,
+	// which a few miners with a clock ahead can push past the local clock
+	if n.state.IsTIP6Fork() && n.state.MedianTimePast() >= uint64(time.Now().Unix()) {
+		blockToMine = blockToMine.WithTime(n.state.MedianTimePast() + 1)
+	}
+
+	if n.state.IsTIP2Fork() {
+		blockToMine, err = blockToMine.WithTxRoot()
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.state.IsTIP3Fork() {
+		stateRoot, err := n.state.NextStateRoot(n.info.Account, pendingTXs)
+		if err != nil {
+			return err
+		}
+
+		blockToMine = blockToMine.WithStateRoot(stateRoot)
+	}
+
+	difficulty := n.miningDifficulty
+	if n.state.IsTIP5Fork() {
+		blockToMine = blockToMine.WithBits(n.state.NextBlockBits())
+	} else if n.state.IsTIP4Fork() {
+		difficulty = n.state.NextBlockDifficulty()
+		blockToMine = blockToMine.WithDifficulty(difficulty)
+	}
+
+	minedBlock, err := miner.Mine(ctx, blockToMine, difficulty)
 	if err != nil {
 		return err
 	}
 
 	n.removeMinedPendingTXs(minedBlock)
 
-	if _, err := n.state.AddBlock(minedBlock); err != nil {
+	update, err := n.state.ImportBlock(minedBlock)
+	if err != nil {
 		return err
 	}
 
+	n.restoreOrphanedTXs(update)
+
 	return nil
 }
 
@@ -81,6 +130,7 @@ func (n *Node) removeMinedPendingTXs(block database.Block) {
 	}
 }
 
+// getPendingTXsAsArray returns the pending TXs in the order they are mined in, by time and each sender's by nonce.
 func (n *Node) getPendingTXsAsArray() []database.SignedTx {
 	txs := make([]database.SignedTx, len(n.pendingTXs))
 
@@ -90,5 +140,35 @@ func (n *Node) getPendingTXsAsArray() []database.SignedTx {
 		i++
 	}
 
-	return txs
+	return database.OrderTXs(txs)
+}
+
+// restoreOrphanedTXs puts the TXs of blocks reverted by a chain reorganisation back into the pending TXs pool,
+// unless the new main chain blocks include them as well, so they get mined again. Their coinbases are dropped.
+func (n *Node) restoreOrphanedTXs(update database.ChainUpdate) {
+	if !update.IsReorg() {
+		return
+	}
+
+	appliedTXs := make(map[string]bool)
+	for _, block := range update.Applied {
+		for _, tx := range block.TXs {
+			txHash, _ := tx.Hash()
+			appliedTXs[txHash.Hex()] = true
+		}
+	}
+
+	for _, block := range update.Reverted {
+		for _, tx := range block.TXs {
+			txHash, _ := tx.Hash()
+			if appliedTXs[txHash.Hex()] || tx.IsCoinbase() {
+				continue
+			}
+
+			fmt.Printf("\t-restoring orphaned TX: %s\n", txHash.Hex())
+
+			delete(n.archivedTXs, txHash.Hex())
+			n.pendingTXs[txHash.Hex()] = tx
+		}
+	}
 }
@@ -24,6 +24,26 @@ const (
 	endpointBalances = "/balances/list"
 	endpointStatus   = "/node/status"
 	endpointAddTx    = "/tx/add"
+	endpointSendRaw  = "/tx/send_raw"
+
+	endpointBalancesQueryKeyBlock = "block"
+
+	endpointTxProof             = "/tx/proof"
+	endpointTxProofQueryKeyHash = "hash"
+
+	endpointTxGet             = "/tx/get"
+	endpointTxGetQueryKeyHash = "hash"
+
+	endpointTxReceipt             = "/tx/receipt"
+	endpointTxReceiptQueryKeyHash = "hash"
+
+	endpointAccountTXs                = "/account/txs"
+	endpointAccountTXsQueryKeyAddress = "address"
+	endpointAccountTXsQueryKeyFrom    = "from"
+	endpointAccountTXsQueryKeyLimit   = "limit"
+	accountTXsMaxLimit                = 100
+
+	endpointChainSupply = "/chain/supply"
 
 	endpointSync                  = "/node/sync"
 	endpointSyncQueryKeyFromBlock = "fromBlock"
@@ -183,6 +203,30 @@ func (n *Node) startHttpServer(ctx context.Context, isSSLDisabled bool, sslEmail
 		txAddHandler(w, r, n)
 	})
 
+	router.HandleFunc(endpointSendRaw, func(w http.ResponseWriter, r *http.Request) {
+		txSendRawHandler(w, r, n)
+	})
+
+	router.HandleFunc(endpointTxProof, func(w http.ResponseWriter, r *http.Request) {
+		txProofHandler(w, r, n.state)
+	})
+
+	router.HandleFunc(endpointTxGet, func(w http.ResponseWriter, r *http.Request) {
+		txGetHandler(w, r, n.state)
+	})
+
+	router.HandleFunc(endpointTxReceipt, func(w http.ResponseWriter, r *http.Request) {
+		txReceiptHandler(w, r, n.state)
+	})
+
+	router.HandleFunc(endpointAccountTXs, func(w http.ResponseWriter, r *http.Request) {
+		accountTXsHandler(w, r, n.state)
+	})
+
+	router.HandleFunc(endpointChainSupply, func(w http.ResponseWriter, r *http.Request) {
+		chainSupplyHandler(w, r, n.state)
+	})
+
 	router.HandleFunc(endpointStatus, func(w http.ResponseWriter, r *http.Request) {
 		statusHandler(w, r, n)
 	})
@@ -3,11 +3,16 @@ package node
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"the-blockchain-bar/miner"
 	"the-blockchain-bar/resources"
@@ -26,6 +31,7 @@ import (
 const (
 	nodeTestVersion             = "0.0.0-alpha-test"
 	defaultTestMiningDifficulty = 2
+	testChainID                 = "the-blockchain-bar-test"
 )
 
 func TestNode_Run(t *testing.T) {
@@ -63,7 +69,8 @@ func TestNode_Mining(t *testing.T) {
 	// Schedule a new TX in 3 seconds from now, in a separate thread because the n.Run() few lines below is a blocking call
 	go func() {
 		time.Sleep(time.Second * 1)
-		tx := database.NewBaseTx(andrej, babayaga, 1, 1, "")
+		tx := database.NewBaseTx(andrej, babayaga, big.NewInt(1), 1, "")
+		tx.ChainID = testChainID
 		signedTx, err := wallet.SignTxWithKeystoreAccount(tx, andrej, resources.TestKsAccountsPwd, wallet.GetKeystoreDirPath(dataDir))
 		if err != nil {
 			t.Error(err)
@@ -77,7 +84,8 @@ func TestNode_Mining(t *testing.T) {
 	// Schedule a new TX in 12 seconds from now simulating that it came in - while the first TX is being mined
 	go func() {
 		time.Sleep(time.Second * 30)
-		tx := database.NewBaseTx(andrej, babayaga, 2, 2, "")
+		tx := database.NewBaseTx(andrej, babayaga, big.NewInt(2), 2, "")
+		tx.ChainID = testChainID
 		signedTx, err := wallet.SignTxWithKeystoreAccount(tx, andrej, resources.TestKsAccountsPwd, wallet.GetKeystoreDirPath(dataDir))
 		if err != nil {
 			t.Error(err)
@@ -138,9 +146,10 @@ func TestNode_MiningStopsOnNewSyncedBlock(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			genesisBalances := make(map[common.Address]uint)
-			genesisBalances[andrej] = 1000000
-			genesis := database.Genesis{Balances: genesisBalances, ForkTIP1: tc.ForkTIP1}
+			genesisBalances := make(map[common.Address]*big.Int)
+			genesisBalances[andrej] = big.NewInt(1000000)
+			forkTIP8 := uint64(0)
+			genesis := database.Genesis{Balances: genesisBalances, ChainID: testChainID, ForkTIP1: tc.ForkTIP1, ForkTIP8: &forkTIP8}
 			genesisJson, err := json.Marshal(genesis)
 			if err != nil {
 				t.Fatal(err)
@@ -173,14 +182,16 @@ func TestNode_MiningStopsOnNewSyncedBlock(t *testing.T) {
 			// Allow the test to run for 30 minutes, in the worst case
 			ctx, closeNode := context.WithTimeout(context.Background(), time.Minute*30)
 
-			tx1 := database.NewBaseTx(andrej, babayaga, 1, 1, "")
-			tx2 := database.NewBaseTx(andrej, babayaga, 2, 2, "")
+			tx1 := database.NewBaseTx(andrej, babayaga, big.NewInt(1), 1, "")
+			tx1.ChainID = testChainID
+			tx2 := database.NewBaseTx(andrej, babayaga, big.NewInt(2), 2, "")
+			tx2.ChainID = testChainID
 
 			if tc.name == "Legacy" {
-				tx1.Gas = 0
-				tx1.GasPrice = 0
-				tx2.Gas = 0
-				tx2.GasPrice = 0
+				tx1.Gas = big.NewInt(0)
+				tx1.GasPrice = big.NewInt(0)
+				tx2.Gas = big.NewInt(0)
+				tx2.GasPrice = big.NewInt(0)
 			}
 
 			signedTx1, err := wallet.SignTxWithKeystoreAccount(tx1, andrej, resources.TestKsAccountsPwd, wallet.GetKeystoreDirPath(dataDir))
@@ -283,38 +294,44 @@ func TestNode_MiningStopsOnNewSyncedBlock(t *testing.T) {
 				// Take a snapshot of the DB balances
 				// before the mining is finished and the 2 blocks
 				// are created.
-				startingAndrejBalance := n.state.Balances[andrej]
-				startingBabayagaBalance := n.state.Balances[babayaga]
+				startingAndrejBalance := n.state.Balance(andrej)
+				startingBabayagaBalance := n.state.Balance(babayaga)
 
 				// Wait until the 30 mins timeout is reached or
 				// the 2 blocks got already mined and the closeNode() was triggered
 				<-ctx.Done()
 
-				endAndrejBalance := n.state.Balances[andrej]
-				endBabayagaBalance := n.state.Balances[babayaga]
+				endAndrejBalance := n.state.Balance(andrej)
+				endBabayagaBalance := n.state.Balance(babayaga)
 
 				// In TX1 Andrej transferred 1 TBB token to BabaYaga
 				// In TX2 Andrej transferred 2 TBB tokens to BabaYaga
 
-				var expectedEndAndrejBalance uint
-				var expectedEndBabayagaBalance uint
+				expectedEndAndrejBalance := new(big.Int).Set(startingAndrejBalance)
+				expectedEndBabayagaBalance := new(big.Int).Set(startingBabayagaBalance)
 
 				// Andrej will occur the cost of SENDING 2 TXs but will collect the reward for mining one block with tx1 in it
 				// Babayaga will RECEIVE value from 2 TXs and will also collect the reward for mining one block with tx2 in it
 
-				if n.state.IsTIP1Fork() {
-					expectedEndAndrejBalance = startingAndrejBalance - tx1.Cost(true) - tx2.Cost(true) + database.BlockReward + tx1.GasCost()
-					expectedEndBabayagaBalance = startingBabayagaBalance + tx1.Value + tx2.Value + database.BlockReward + tx2.GasCost()
+				isTIP1Fork := n.state.IsTIP1Fork()
+				expectedEndAndrejBalance.Sub(expectedEndAndrejBalance, tx1.Cost(isTIP1Fork))
+				expectedEndAndrejBalance.Sub(expectedEndAndrejBalance, tx2.Cost(isTIP1Fork))
+				expectedEndAndrejBalance.Add(expectedEndAndrejBalance, big.NewInt(database.BlockReward))
+				expectedEndBabayagaBalance.Add(expectedEndBabayagaBalance, new(big.Int).Add(new(big.Int).Add(tx1.Value, tx2.Value), big.NewInt(database.BlockReward)))
+
+				if isTIP1Fork {
+					expectedEndAndrejBalance.Add(expectedEndAndrejBalance, tx1.GasCost())
+					expectedEndBabayagaBalance.Add(expectedEndBabayagaBalance, tx2.GasCost())
 				} else {
-					expectedEndAndrejBalance = startingAndrejBalance - tx1.Cost(false) - tx2.Cost(false) + database.BlockReward + database.TxFee
-					expectedEndBabayagaBalance = startingBabayagaBalance + tx1.Value + tx2.Value + database.BlockReward + database.TxFee
+					expectedEndAndrejBalance.Add(expectedEndAndrejBalance, big.NewInt(int64(database.TxFee)))
+					expectedEndBabayagaBalance.Add(expectedEndBabayagaBalance, big.NewInt(int64(database.TxFee)))
 				}
 
-				if endAndrejBalance != expectedEndAndrejBalance {
+				if endAndrejBalance.Cmp(expectedEndAndrejBalance) != 0 {
 					t.Errorf("Andrej expected end balance is %d not %d", expectedEndAndrejBalance, endAndrejBalance)
 				}
 
-				if endBabayagaBalance != expectedEndBabayagaBalance {
+				if endBabayagaBalance.Cmp(expectedEndBabayagaBalance) != 0 {
 					t.Errorf("BabaYaga expected end balance is %d not %d", expectedEndBabayagaBalance, endBabayagaBalance)
 				}
 
@@ -349,7 +366,8 @@ func TestNode_ForgedTx(t *testing.T) {
 
 	txValue := uint(5)
 	txNonce := uint(1)
-	tx := database.NewBaseTx(andrej, babayaga, txValue, txNonce, "")
+	tx := database.NewBaseTx(andrej, babayaga, new(big.Int).SetUint64(uint64(txValue)), txNonce, "")
+	tx.ChainID = testChainID
 
 	validSignedTx, err := wallet.SignTxWithKeystoreAccount(tx, andrej, resources.TestKsAccountsPwd, wallet.GetKeystoreDirPath(dataDir))
 	if err != nil {
@@ -377,7 +395,8 @@ func TestNode_ForgedTx(t *testing.T) {
 						// Attempt to forge the same TX but with modified time
 						// Because the TX.time changed, the TX.signature will be considered forged
 						// database.NewTx() changes the TX time
-						forgedTx := database.NewBaseTx(andrej, babayaga, txValue, txNonce, "")
+						forgedTx := database.NewBaseTx(andrej, babayaga, new(big.Int).SetUint64(uint64(txValue)), txNonce, "")
+						forgedTx.ChainID = testChainID
 						// Use the signature from a valid TX
 						forgedSignedTx := database.NewSignedTx(forgedTx, validSignedTx.Sig)
 
@@ -397,7 +416,7 @@ func TestNode_ForgedTx(t *testing.T) {
 		t.Fatal("only one tx was supposed to be mined. the second tx was forged")
 	}
 
-	if n.state.Balances[babayaga] != txValue {
+	if n.state.Balance(babayaga).Cmp(big.NewInt(int64(txValue))) != 0 {
 		t.Fatal("forged tx succeeded")
 	}
 }
@@ -416,7 +435,8 @@ func TestNode_ReplayedTx(t *testing.T) {
 
 	txValue := uint(5)
 	txNonce := uint(1)
-	tx := database.NewBaseTx(andrej, babayaga, txValue, txNonce, "")
+	tx := database.NewBaseTx(andrej, babayaga, new(big.Int).SetUint64(uint64(txValue)), txNonce, "")
+	tx.ChainID = testChainID
 
 	signedTx, err := wallet.SignTxWithKeystoreAccount(tx, andrej, resources.TestKsAccountsPwd, wallet.GetKeystoreDirPath(dataDir))
 	if err != nil {
@@ -461,8 +481,8 @@ func TestNode_ReplayedTx(t *testing.T) {
 
 	_ = n.Run(ctx, true, "")
 
-	if n.state.Balances[babayaga] != txValue {
-		t.Fatalf("replayed attack was successful. babayaga balance is:%d should be:%d", n.state.Balances[babayaga], txValue)
+	if n.state.Balance(babayaga).Cmp(big.NewInt(int64(txValue))) != 0 {
+		t.Fatalf("replayed attack was successful. babayaga balance is:%d should be:%d", n.state.Balance(babayaga), txValue)
 	}
 
 	if n.state.LatestBlock().Header.Number == 1 {
@@ -512,13 +532,14 @@ func TestNode_MiningSpamTransactions(t *testing.T) {
 				// Schedule 4 transfers from Andrej -> BabaYaga
 				for i := uint(1); i <= txCount; i++ {
 					txNonce := i
-					tx := database.NewBaseTx(andrej, babayaga, txValue, txNonce, "")
+					tx := database.NewBaseTx(andrej, babayaga, new(big.Int).SetUint64(uint64(txValue)), txNonce, "")
+					tx.ChainID = testChainID
 					// Ensure every TX has a unique timestamp and the nonce 0 has oldest timestamp, nonce 1 younger timestamp etc
 					tx.Time = now - uint64(txCount-i*100)
 
 					if tc.name == "Legacy" {
-						tx.Gas = 0
-						tx.GasPrice = 0
+						tx.Gas = big.NewInt(0)
+						tx.GasPrice = big.NewInt(0)
 					}
 
 					signedTx, err := wallet.SignTxWithKeystoreAccount(tx, andrej, resources.TestKsAccountsPwd, wallet.GetKeystoreDirPath(dataDir))
@@ -554,42 +575,36 @@ func TestNode_MiningSpamTransactions(t *testing.T) {
 			// Run the node, mining and everything in a blocking call (hence the go-routines before)
 			_ = n.Run(ctx, true, "")
 
-			var expectedAndrejBalance uint
-			var expectedBabayagaBalance uint
-			var expectedMinerBalance uint
+			expectedAndrejBalance := big.NewInt(int64(andrejBalance))
+			expectedBabayagaBalance := big.NewInt(int64(babayagaBalance + (txCount * txValue)))
+			expectedMinerBalance := big.NewInt(int64(minerBalance + database.BlockReward))
 
 			// in nutshell: sender occurs tx.Cost(), receiver gains tx.Value() and miner collects tx.GasCost()
 			if n.state.IsTIP1Fork() {
-				expectedAndrejBalance = andrejBalance
-				expectedMinerBalance = minerBalance + database.BlockReward
-
 				for _, tx := range spamTXs {
-					expectedAndrejBalance -= tx.Cost(true)
-					expectedMinerBalance += tx.GasCost()
+					expectedAndrejBalance.Sub(expectedAndrejBalance, tx.Cost(true))
+					expectedMinerBalance.Add(expectedMinerBalance, tx.GasCost())
 				}
-
-				expectedBabayagaBalance = babayagaBalance + (txCount * txValue)
 			} else {
-				expectedAndrejBalance = andrejBalance - (txCount * txValue) - (txCount * database.TxFee)
-				expectedBabayagaBalance = babayagaBalance + (txCount * txValue)
-				expectedMinerBalance = minerBalance + database.BlockReward + (txCount * database.TxFee)
+				expectedAndrejBalance.Sub(expectedAndrejBalance, big.NewInt(int64((txCount*txValue)+(txCount*database.TxFee))))
+				expectedMinerBalance.Add(expectedMinerBalance, big.NewInt(int64(txCount*database.TxFee)))
 			}
 
-			if n.state.Balances[andrej] != expectedAndrejBalance {
-				t.Errorf("andrej balance is incorrect. expected: %d. got: %d", expectedAndrejBalance, n.state.Balances[andrej])
+			if n.state.Balance(andrej).Cmp(expectedAndrejBalance) != 0 {
+				t.Errorf("andrej balance is incorrect. expected: %d. got: %d", expectedAndrejBalance, n.state.Balance(andrej))
 			}
 
-			if n.state.Balances[babayaga] != expectedBabayagaBalance {
-				t.Errorf("babaYaga balance is incorrect. expected: %d. got: %d", expectedBabayagaBalance, n.state.Balances[babayaga])
+			if n.state.Balance(babayaga).Cmp(expectedBabayagaBalance) != 0 {
+				t.Errorf("babaYaga balance is incorrect. expected: %d. got: %d", expectedBabayagaBalance, n.state.Balance(babayaga))
 			}
 
-			if n.state.Balances[miner] != expectedMinerBalance {
-				t.Errorf("miner balance is incorrect. expected: %d. got: %d", expectedMinerBalance, n.state.Balances[miner])
+			if n.state.Balance(miner).Cmp(expectedMinerBalance) != 0 {
+				t.Errorf("miner balance is incorrect. expected: %d. got: %d", expectedMinerBalance, n.state.Balance(miner))
 			}
 
-			t.Logf("andrej final balance: %d TBB", n.state.Balances[andrej])
-			t.Logf("babayaga final balance: %d TBB", n.state.Balances[babayaga])
-			t.Logf("miner final balance: %d TBB", n.state.Balances[miner])
+			t.Logf("andrej final balance: %d TBB", n.state.Balance(andrej))
+			t.Logf("babayaga final balance: %d TBB", n.state.Balance(babayaga))
+			t.Logf("miner final balance: %d TBB", n.state.Balance(miner))
 		})
 	}
 }
@@ -658,9 +673,10 @@ func setupTestNodeDir(andrejBalance uint, forkTip1 uint64) (dataDir string, andr
 		return "", common.Address{}, common.Address{}, err
 	}
 
-	genesisBalances := make(map[common.Address]uint)
-	genesisBalances[andrej] = andrejBalance
-	genesis := database.Genesis{Balances: genesisBalances, ForkTIP1: forkTip1}
+	genesisBalances := make(map[common.Address]*big.Int)
+	genesisBalances[andrej] = new(big.Int).SetUint64(uint64(andrejBalance))
+	forkTIP8 := uint64(0)
+	genesis := database.Genesis{Balances: genesisBalances, ChainID: testChainID, ForkTIP1: forkTip1, ForkTIP8: &forkTIP8}
 	genesisJson, err := json.Marshal(genesis)
 	if err != nil {
 		return "", common.Address{}, common.Address{}, err
@@ -676,3 +692,174 @@ func setupTestNodeDir(andrejBalance uint, forkTip1 uint64) (dataDir string, andr
 
 	return dataDir, andrej, babayaga, nil
 }
+
+func TestTxSendRawHandler(t *testing.T) {
+	dataDir, andrej, babayaga, err := setupTestNodeDir(1000000, 0)
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	n := New(dataDir, "127.0.0.1", 8086, andrej, PeerNode{}, nodeTestVersion, defaultTestMiningDifficulty)
+	n.state, err = database.NewStateFromDisk(dataDir, defaultTestMiningDifficulty)
+	require.NoError(t, err)
+	defer n.state.Close()
+
+	tx := database.NewBaseTx(andrej, babayaga, big.NewInt(5), 1, "")
+	tx.ChainID = testChainID
+
+	signedTx, err := wallet.SignTxWithKeystoreAccount(tx, andrej, resources.TestKsAccountsPwd, wallet.GetKeystoreDirPath(dataDir))
+	require.NoError(t, err)
+
+	forgedTx := signedTx
+	forgedTx.Value = big.NewInt(500)
+
+	sendRaw := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		txSendRawHandler(w, httptest.NewRequest(http.MethodPost, endpointSendRaw, strings.NewReader(body)), n)
+
+		return w
+	}
+
+	forgedJson, err := json.Marshal(forgedTx)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, sendRaw(string(forgedJson)).Code)
+
+	// A signature that can't even be parsed is forged too, as when validating blocks
+	malformedTx := signedTx
+	malformedTx.Sig = []byte{1, 2}
+	malformedJson, err := json.Marshal(malformedTx)
+	require.NoError(t, err)
+
+	w := sendRaw(string(malformedJson))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "forged_signature")
+
+	assert.Equal(t, http.StatusBadRequest, sendRaw(`{"from": "0x3eb92807f1f91a8d4d85bc908c7f86dcddb1df57"`).Code)
+
+	signedJson, err := json.Marshal(signedTx)
+	require.NoError(t, err)
+
+	w = sendRaw(string(signedJson))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	res := txSendRawResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+
+	txHash, err := signedTx.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, txHash, res.Hash)
+	assert.Contains(t, n.pendingTXs, txHash.Hex())
+
+	// TXs signed offline in a row are accepted while the previous ones are still pending, in nonce order
+	signRaw := func(value int64, nonce uint) string {
+		tx := database.NewBaseTx(andrej, babayaga, big.NewInt(value), nonce, "")
+		tx.ChainID = testChainID
+
+		signedTx, err := wallet.SignTxWithKeystoreAccount(tx, andrej, resources.TestKsAccountsPwd, wallet.GetKeystoreDirPath(dataDir))
+		require.NoError(t, err)
+
+		signedJson, err := json.Marshal(signedTx)
+		require.NoError(t, err)
+
+		return string(signedJson)
+	}
+
+	assert.Equal(t, http.StatusOK, sendRaw(signRaw(6, 2)).Code)
+	assert.Equal(t, http.StatusConflict, sendRaw(signRaw(7, 4)).Code)
+	assert.Equal(t, http.StatusOK, sendRaw(signRaw(7, 3)).Code)
+	assert.Len(t, n.pendingTXs, 3)
+
+	// Sending a pending TX again is a no-op
+	assert.Equal(t, http.StatusOK, sendRaw(string(signedJson)).Code)
+	assert.Len(t, n.pendingTXs, 3)
+
+	// The balance must cover the pending TXs too
+	w = sendRaw(signRaw(1000000-3*database.TxGas-18, 4))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "insufficient_balance")
+}
+
+func TestTxAddHandler(t *testing.T) {
+	dataDir, andrej, babayaga, err := setupTestNodeDir(1000000, 0)
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	n := New(dataDir, "127.0.0.1", 8086, andrej, PeerNode{}, nodeTestVersion, defaultTestMiningDifficulty)
+	n.state, err = database.NewStateFromDisk(dataDir, defaultTestMiningDifficulty)
+	require.NoError(t, err)
+	defer n.state.Close()
+
+	add := func(value int64) *httptest.ResponseRecorder {
+		reqJson, err := json.Marshal(txAddRequest{
+			From:             andrej.String(),
+			To:               babayaga.String(),
+			Value:            big.NewInt(value),
+			KeystorePassword: resources.TestKsAccountsPwd,
+			Gas:              big.NewInt(database.TxGas),
+			GasPrice:         big.NewInt(database.TxGasPriceDefault),
+		})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		txAddHandler(w, httptest.NewRequest(http.MethodPost, endpointAddTx, strings.NewReader(string(reqJson))), n)
+
+		return w
+	}
+
+	// Each TX takes the nonce following the sender's pending TXs
+	require.Equal(t, http.StatusOK, add(5).Code)
+	require.Equal(t, http.StatusOK, add(6).Code)
+
+	nonces := make([]uint, 0)
+	for _, tx := range n.getPendingTXsAsArray() {
+		nonces = append(nonces, tx.Nonce)
+	}
+	assert.ElementsMatch(t, []uint{1, 2}, nonces)
+}
+
+func TestWriteErrorResponse(t *testing.T) {
+	testCases := map[string]struct {
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		"invalid nonce": {
+			err:        &database.InvalidNonceError{Account: database.NewAccount(DefaultMiner), Expected: 1, Actual: 2},
+			wantStatus: http.StatusConflict,
+			wantCode:   "invalid_nonce",
+		},
+		"insufficient balance": {
+			err:        &database.InsufficientBalanceError{Account: database.NewAccount(DefaultMiner), Balance: big.NewInt(1), Cost: big.NewInt(2)},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "insufficient_balance",
+		},
+		"wrapped not found": {
+			err:        fmt.Errorf("%w '%x'", database.ErrTxNotFound, database.Hash{}),
+			wantStatus: http.StatusNotFound,
+			wantCode:   "tx_not_found",
+		},
+		"invalid request": {
+			err:        fmt.Errorf("%w. 'pwd' is empty", errInvalidRequest),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_request",
+		},
+		"internal": {
+			err:        errors.New("disk is full"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal_error",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeErrorResponse(w, tc.err)
+
+			res := errorResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, tc.err.Error(), res.Error)
+		})
+	}
+}
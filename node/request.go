@@ -2,31 +2,36 @@ package node
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 )
 
+// errInvalidRequest is a request the client has to fix, e.g. a malformed body or query parameter.
+var errInvalidRequest = errors.New("invalid request")
+
 type txAddRequest struct {
-	From             string `json:"from"`
-	To               string `json:"to"`
-	Value            uint   `json:"value"`
-	Data             string `json:"data"`
-	KeystorePassword string `json:"pwd"`
-	Gas              uint   `json:"gas"`
-	GasPrice         uint   `json:"gas_price"`
+	From             string   `json:"from"`
+	To               string   `json:"to"`
+	Value            *big.Int `json:"value"`
+	Data             string   `json:"data"`
+	KeystorePassword string   `json:"pwd"`
+	Gas              *big.Int `json:"gas"`
+	GasPrice         *big.Int `json:"gas_price"`
 }
 
 func requestFromBody(r *http.Request, target interface{}) error {
 	reqBodyJson, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return fmt.Errorf("unable to read request body. %s", err.Error())
+		return fmt.Errorf("%w. unable to read request body. %s", errInvalidRequest, err.Error())
 	}
 
 	defer r.Body.Close()
 
 	if err = json.Unmarshal(reqBodyJson, target); err != nil {
-		return fmt.Errorf("unable to unmarshal request body. %s", err.Error())
+		return fmt.Errorf("%w. unable to unmarshal request body. %s", errInvalidRequest, err.Error())
 	}
 
 	return nil
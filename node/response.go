@@ -2,30 +2,104 @@ package node
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"the-blockchain-bar/database"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 )
 
 type errorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// errorStatuses maps the errors requests fail with to an HTTP status and a machine-readable code, the first match wins.
+// Other errors are internal errors.
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{errInvalidRequest, http.StatusBadRequest, "invalid_request"},
+	{keystore.ErrNoMatch, http.StatusNotFound, "unknown_account"},
+	{keystore.ErrDecrypt, http.StatusUnauthorized, "wrong_password"},
+	{database.ErrBlockNotFound, http.StatusNotFound, "block_not_found"},
+	{database.ErrTxNotFound, http.StatusNotFound, "tx_not_found"},
+	{database.ErrHistoryUnavailable, http.StatusNotFound, "history_unavailable"},
+
+	{database.ErrForgedSignature, http.StatusUnprocessableEntity, "forged_signature"},
+	{database.ErrInvalidNonce, http.StatusConflict, "invalid_nonce"},
+	{database.ErrInsufficientBalance, http.StatusUnprocessableEntity, "insufficient_balance"},
+	{database.ErrInvalidGas, http.StatusUnprocessableEntity, "invalid_gas"},
+	{database.ErrInvalidValue, http.StatusUnprocessableEntity, "invalid_value"},
+	{database.ErrInvalidChainID, http.StatusUnprocessableEntity, "invalid_chain_id"},
+	{database.ErrInvalidTxOrder, http.StatusUnprocessableEntity, "invalid_tx_order"},
+	{database.ErrInvalidMultisig, http.StatusUnprocessableEntity, "invalid_multisig"},
+
+	{database.ErrUnknownParent, http.StatusUnprocessableEntity, "unknown_parent"},
+	{database.ErrBadBlockNumber, http.StatusUnprocessableEntity, "bad_block_number"},
+	{database.ErrBadParent, http.StatusUnprocessableEntity, "bad_parent"},
+	{database.ErrBadPoW, http.StatusUnprocessableEntity, "bad_pow"},
+	{database.ErrBadDifficulty, http.StatusUnprocessableEntity, "bad_difficulty"},
+	{database.ErrBadBlockTime, http.StatusUnprocessableEntity, "bad_block_time"},
+	{database.ErrBadTxRoot, http.StatusUnprocessableEntity, "bad_tx_root"},
+	{database.ErrBadStateRoot, http.StatusUnprocessableEntity, "bad_state_root"},
+	{database.ErrBlockTooLarge, http.StatusUnprocessableEntity, "block_too_large"},
+	{database.ErrBadCoinbase, http.StatusUnprocessableEntity, "bad_coinbase"},
 }
 
 type balancesResponse struct {
-	Hash     database.Hash           `json:"block_hash"`
-	Balances map[common.Address]uint `json:"balances"`
+	Hash     database.Hash               `json:"block_hash"`
+	Number   uint64                      `json:"block_number"`
+	Balances map[common.Address]*big.Int `json:"balances"`
 }
 
 type txAddResponse struct {
 	Success bool `json:"success"`
 }
 
+type txSendRawResponse struct {
+	Success bool          `json:"success"`
+	Hash    database.Hash `json:"tx_hash"`
+}
+
+type txProofResponse struct {
+	BlockHash   database.Hash        `json:"block_hash"`
+	BlockHeader database.BlockHeader `json:"block_header"`
+	Proof       database.TxProof     `json:"proof"`
+}
+
+type txGetResponse struct {
+	Tx database.SignedTx `json:"tx"`
+	database.TxLocation
+}
+
+type accountTXsResponse struct {
+	Account common.Address  `json:"account"`
+	Total   uint            `json:"total"` // all the account TXs, to page through them
+	From    uint            `json:"from"`
+	TXs     []txGetResponse `json:"txs"`
+}
+
+type chainSupplyResponse struct {
+	Hash            database.Hash `json:"block_hash"`
+	Number          uint64        `json:"block_number"`
+	Supply          *big.Int      `json:"supply"` // genesis balances and all the block rewards
+	GenesisSupply   *big.Int      `json:"genesis_supply"`
+	MaxSupply       *big.Int      `json:"max_supply"` // null if uncapped
+	NextBlockReward *big.Int      `json:"next_block_reward"`
+	HalvingInterval uint64        `json:"halving_interval"` // zero if never halved
+}
+
 type statusResponse struct {
 	Hash        database.Hash       `json:"block_hash"`
 	Number      uint64              `json:"block_number"`
+	TotalWork   *big.Int            `json:"total_work"`
 	KnownPeers  map[string]PeerNode `json:"peers_known"`
 	PendingTXs  []database.SignedTx `json:"pending_txs"`
 	NodeVersion string              `json:"node_version"`
@@ -55,9 +129,17 @@ func writeSuccessfulResponse(w http.ResponseWriter, content interface{}) {
 }
 
 func writeErrorResponse(w http.ResponseWriter, err error) {
-	jsonErrRes, _ := json.Marshal(errorResponse{err.Error()})
+	status, code := http.StatusInternalServerError, "internal_error"
+	for _, errorStatus := range errorStatuses {
+		if errors.Is(err, errorStatus.err) {
+			status, code = errorStatus.status, errorStatus.code
+			break
+		}
+	}
+
+	jsonErrRes, _ := json.Marshal(errorResponse{Error: err.Error(), Code: code})
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
+	w.WriteHeader(status)
 	w.Write(jsonErrRes)
 }
 
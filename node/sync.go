@@ -22,6 +22,8 @@ func (n *Node) sync(ctx context.Context) error {
 }
 
 func (n *Node) doSync() {
+	n.importQueuedBlocks()
+
 	for _, peer := range n.knownPeers {
 		if (n.info.IP == peer.IP && n.info.Port == peer.Port) || peer.IP == "" {
 			continue
@@ -108,45 +110,69 @@ func (n *Node) joinKnownPeers(peer PeerNode) error {
 }
 
 func (n *Node) syncBlocks(peer PeerNode, status statusResponse) error {
-	localBlockNumber := n.state.LatestBlock().Header.Number
-
-	// If the peer has no blocks, ignore it
-	if status.Hash.IsEmpty() {
+	// If the peer has no blocks, or we already know its latest block, ignore it
+	if status.Hash.IsEmpty() || n.state.IsKnownBlock(status.Hash) {
 		return nil
 	}
 
-	// If the peer has fewer blocks than us, ignore it
-	if status.Number < localBlockNumber {
+	// If the peer's chain doesn't have more work than ours, ignore it.
+	// Peers prior to the fork choice rule don't report their work, their height is compared instead.
+	if status.TotalWork != nil {
+		if status.TotalWork.Cmp(n.state.TotalWork()) <= 0 {
+			return nil
+		}
+	} else if !n.state.LatestBlockHash().IsEmpty() && status.Number <= n.state.LatestBlock().Header.Number {
 		return nil
 	}
 
-	// If it's the genesis block, and we already synced it, then ignore it
-	if status.Number == 0 && !n.state.LatestBlockHash().IsEmpty() {
-		return nil
-	}
+	fmt.Printf("found a chain with more work on peer %s, up to block %d\n", peer.TcpAddress(), status.Number)
 
-	newBlocksCount := status.Number - localBlockNumber
-	if localBlockNumber == 0 && status.Number == 0 { // Display found 1 new block if we sync the genesis block 0
-		newBlocksCount = 1
-	}
-	fmt.Printf("found %d new blocks from peer %s\n", newBlocksCount, peer.TcpAddress())
+	// Walk back our chain until the peer recognises one of our blocks, the blocks after it may fork from ours
+	blocks := make([]database.Block, 0)
+	for _, hash := range n.state.BlockLocator() {
+		var err error
+		blocks, err = fetchBlocksFromPeer(peer, hash)
+		if err != nil {
+			return err
+		}
 
-	blocks, err := fetchBlocksFromPeer(peer, n.state.LatestBlockHash())
-	if err != nil {
-		return err
+		if len(blocks) > 0 {
+			break
+		}
 	}
 
 	for _, block := range blocks {
-		if _, err = n.state.AddBlock(block); err != nil {
+		update, err := n.state.ImportBlock(block)
+		if err != nil {
 			return err
 		}
 
-		n.newSyncedBlocks <- block
+		// the next blocks build on the queued one, they are fetched again once it's imported
+		if update.Queued {
+			break
+		}
+
+		n.restoreOrphanedTXs(update)
+
+		for _, applied := range update.Applied {
+			n.newSyncedBlocks <- applied
+		}
 	}
 
 	return nil
 }
 
+// importQueuedBlocks imports the blocks received ahead of the local clock whose time came.
+func (n *Node) importQueuedBlocks() {
+	for _, update := range n.state.ImportQueuedBlocks() {
+		n.restoreOrphanedTXs(update)
+
+		for _, applied := range update.Applied {
+			n.newSyncedBlocks <- applied
+		}
+	}
+}
+
 func (n *Node) syncKnownPeers(status statusResponse) error {
 	for _, statusPeer := range status.KnownPeers {
 		if !n.IsKnownPeer(statusPeer) {
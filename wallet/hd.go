@@ -0,0 +1,176 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// HD wallets derive any number of accounts from a single BIP-39 mnemonic, so backing up the mnemonic once
+// backs up all of them. The keys are derived with BIP-32 along the BIP-44 path m/44'/60'/0'/0/i, the same as
+// Ethereum wallets, so a TBB mnemonic restores the same accounts in any of them and the other way around.
+
+// HDBaseDerivationPath is the derivation path of the first account, the account index being its last component.
+var HDBaseDerivationPath = accounts.DefaultBaseDerivationPath
+
+// hdHardenedOffset is the index of the first hardened child key, derived from the parent private key only.
+const hdHardenedOffset = 0x80000000
+
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// ValidateHDAccountRange checks the count accounts from the given index on are all non-hardened,
+// the account index being the last, non-hardened, level of the derivation path.
+func ValidateHDAccountRange(from, count uint32) error {
+	if uint64(from)+uint64(count) > hdHardenedOffset {
+		return fmt.Errorf("account indexes go up to %d, %d accounts from index %d go past it", hdHardenedOffset-1, count, from)
+	}
+
+	return nil
+}
+
+// NewMnemonic returns a new random BIP-39 mnemonic of 12, 15, 18, 21 or 24 words.
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", fmt.Errorf("a mnemonic has 12, 15, 18, 21 or 24 words, not %d", words)
+	}
+
+	entropy, err := bip39.NewEntropy(words / 3 * 32)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+// HDWallet derives the keys of the accounts backed up by a mnemonic.
+type HDWallet struct {
+	masterKey []byte
+	chainCode []byte
+}
+
+// NewHDWallet restores the wallet of the mnemonic, protected by the optional BIP-39 passphrase.
+func NewHDWallet(mnemonic, passphrase string) (*HDWallet, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%w. %s", ErrInvalidMnemonic, err.Error())
+	}
+
+	key, chainCode := hmacSHA512([]byte("Bitcoin seed"), seed)
+	if !isValidHDKey(key) {
+		return nil, fmt.Errorf("%w. the seed doesn't derive a valid master key", ErrInvalidMnemonic)
+	}
+
+	return &HDWallet{masterKey: key, chainCode: chainCode}, nil
+}
+
+// HDDerivationPath returns the derivation path of the account with the given index.
+func HDDerivationPath(index uint32) accounts.DerivationPath {
+	path := make(accounts.DerivationPath, len(HDBaseDerivationPath))
+	copy(path, HDBaseDerivationPath)
+	path[len(path)-1] = index
+
+	return path
+}
+
+// DeriveKey returns the private key at the derivation path.
+func (w *HDWallet) DeriveKey(path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	key, chainCode := w.masterKey, w.chainCode
+
+	for _, index := range path {
+		var err error
+		key, chainCode, err = deriveChildKey(key, chainCode, index)
+		if err != nil {
+			return nil, fmt.Errorf("can't derive the key at %s. %s", path.String(), err.Error())
+		}
+	}
+
+	return crypto.ToECDSA(key)
+}
+
+// DeriveKeystoreAccounts stores in the data dir keystore the count accounts of the mnemonic from the given index on,
+// encrypted with the password. Accounts already in the keystore are kept as they are, so restoring is repeatable.
+func DeriveKeystoreAccounts(dataDir, mnemonic, passphrase, password string, from, count uint32) ([]common.Address, error) {
+	if err := ValidateHDAccountRange(from, count); err != nil {
+		return nil, err
+	}
+
+	w, err := NewHDWallet(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := newKeyStore(dataDir)
+
+	addresses := make([]common.Address, 0, count)
+	for i := uint32(0); i < count; i++ {
+		key, err := w.DeriveKey(HDDerivationPath(from + i))
+		if err != nil {
+			return nil, err
+		}
+
+		account, err := ks.ImportECDSA(key, password)
+		if err != nil && !errors.Is(err, keystore.ErrAccountAlreadyExists) {
+			return nil, err
+		}
+
+		addresses = append(addresses, account.Address)
+	}
+
+	return addresses, nil
+}
+
+// deriveChildKey is the BIP-32 private parent key to private child key derivation.
+func deriveChildKey(key, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	data := make([]byte, 0, 37)
+	if index >= hdHardenedOffset {
+		data = append(data, 0x00)
+		data = append(data, key...)
+	} else {
+		privateKey, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, crypto.CompressPubkey(&privateKey.PublicKey)...)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], index)
+
+	tweak, childChainCode := hmacSHA512(chainCode, data)
+	if !isValidHDKey(tweak) {
+		return nil, nil, fmt.Errorf("index %d derives an invalid key", index)
+	}
+
+	childKey := new(big.Int).Add(new(big.Int).SetBytes(tweak), new(big.Int).SetBytes(key))
+	childKey.Mod(childKey, crypto.S256().Params().N)
+	if childKey.Sign() == 0 {
+		return nil, nil, fmt.Errorf("index %d derives an invalid key", index)
+	}
+
+	return math.PaddedBigBytes(childKey, 32), childChainCode, nil
+}
+
+func hmacSHA512(key, data []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	return sum[:32], sum[32:]
+}
+
+// isValidHDKey tells whether the 256 bits are a valid secp256k1 private key, or tweak to one.
+func isValidHDKey(key []byte) bool {
+	k := new(big.Int).SetBytes(key)
+
+	return k.Sign() > 0 && k.Cmp(crypto.S256().Params().N) < 0
+}
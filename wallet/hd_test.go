@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// BIP-32 test vector 1
+func TestHDWallet_DeriveKey(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	key, chainCode := hmacSHA512([]byte("Bitcoin seed"), seed)
+	w := &HDWallet{masterKey: key, chainCode: chainCode}
+
+	testCases := map[string]string{
+		"m":                      "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+		"m/0'":                   "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		"m/0'/1":                 "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+		"m/0'/1/2'":              "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca",
+		"m/0'/1/2'/2":            "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4",
+		"m/0'/1/2'/2/1000000000": "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8",
+	}
+
+	for path, expected := range testCases {
+		t.Run(path, func(t *testing.T) {
+			derivationPath, err := accounts.ParseDerivationPath(path)
+			if path == "m" {
+				derivationPath, err = accounts.DerivationPath{}, nil
+			}
+			require.NoError(t, err)
+
+			key, err := w.DeriveKey(derivationPath)
+			require.NoError(t, err)
+			assert.Equal(t, expected, hex.EncodeToString(crypto.FromECDSA(key)))
+		})
+	}
+}
+
+func TestDeriveKeystoreAccounts(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "wallet_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	// Same accounts as Ethereum wallets restoring the mnemonic
+	addresses, err := DeriveKeystoreAccounts(dataDir, testMnemonic, "", testKeystoreAccountsPwd, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, addresses, 2)
+	assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", addresses[0].Hex())
+	assert.Len(t, ListKeystoreAccounts(dataDir), 2)
+
+	key, err := DecryptKeystoreAccount(addresses[1], testKeystoreAccountsPwd, GetKeystoreDirPath(dataDir))
+	require.NoError(t, err)
+	assert.Equal(t, addresses[1], key.Address)
+
+	// Restoring is repeatable
+	again, err := DeriveKeystoreAccounts(dataDir, testMnemonic, "", testKeystoreAccountsPwd, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, addresses[1], again[0])
+	assert.Len(t, ListKeystoreAccounts(dataDir), 3)
+
+	// The passphrase derives other accounts
+	other, err := DeriveKeystoreAccounts(dataDir, testMnemonic, "passphrase", testKeystoreAccountsPwd, 0, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, addresses[0], other[0])
+
+	_, err = DeriveKeystoreAccounts(dataDir, strings.Replace(testMnemonic, "about", "abandon", 1), "", testKeystoreAccountsPwd, 0, 1)
+	assert.ErrorIs(t, err, ErrInvalidMnemonic)
+
+	// Hardened indexes aren't account indexes
+	_, err = DeriveKeystoreAccounts(dataDir, testMnemonic, "", testKeystoreAccountsPwd, hdHardenedOffset-1, 2)
+	assert.Error(t, err)
+	assert.Len(t, ListKeystoreAccounts(dataDir), 4)
+}
+
+func TestValidateHDAccountRange(t *testing.T) {
+	assert.NoError(t, ValidateHDAccountRange(0, 1))
+	assert.NoError(t, ValidateHDAccountRange(hdHardenedOffset-1, 1))
+	assert.NoError(t, ValidateHDAccountRange(0, hdHardenedOffset))
+
+	assert.Error(t, ValidateHDAccountRange(hdHardenedOffset-1, 2))
+	assert.Error(t, ValidateHDAccountRange(hdHardenedOffset, 1))
+	assert.Error(t, ValidateHDAccountRange(math.MaxUint32, math.MaxUint32))
+}
+
+func TestNewMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic(24)
+	require.NoError(t, err)
+	assert.Len(t, strings.Fields(mnemonic), 24)
+
+	_, err = NewHDWallet(mnemonic, "")
+	assert.NoError(t, err)
+
+	_, err = NewMnemonic(13)
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ListKeystoreAccounts returns the accounts of the data dir keystore, sorted by the name of their keystore files.
+func ListKeystoreAccounts(dataDir string) []common.Address {
+	ks := newKeyStore(dataDir)
+
+	addresses := make([]common.Address, 0)
+	for _, account := range ks.Accounts() {
+		addresses = append(addresses, account.Address)
+	}
+
+	return addresses
+}
+
+// ImportPrivateKey stores the hex encoded private key in the data dir keystore, encrypted with the password.
+func ImportPrivateKey(dataDir, hexKey, password string) (common.Address, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid private key. %s", err.Error())
+	}
+
+	account, err := newKeyStore(dataDir).ImportECDSA(key, password)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return account.Address, nil
+}
+
+// ImportKeystore stores a keystore JSON, e.g. exported by geth, in the data dir keystore,
+// re-encrypted from its password to the new one.
+func ImportKeystore(dataDir string, keyJson []byte, password, newPassword string) (common.Address, error) {
+	account, err := newKeyStore(dataDir).Import(keyJson, password, newPassword)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return account.Address, nil
+}
+
+// ExportKeystoreAccount returns the keystore JSON of the account, re-encrypted from its password to the new one.
+func ExportKeystoreAccount(dataDir string, account common.Address, password, newPassword string) ([]byte, error) {
+	ks := newKeyStore(dataDir)
+
+	ksAccount, err := ks.Find(accounts.Account{Address: account})
+	if err != nil {
+		return nil, err
+	}
+
+	return ks.Export(ksAccount, password, newPassword)
+}
+
+// ChangeKeystorePassword re-encrypts the account keystore file with the new password.
+func ChangeKeystorePassword(dataDir string, account common.Address, password, newPassword string) error {
+	ks := newKeyStore(dataDir)
+
+	ksAccount, err := ks.Find(accounts.Account{Address: account})
+	if err != nil {
+		return err
+	}
+
+	return ks.Update(ksAccount, password, newPassword)
+}
+
+// PublicKeyHex returns the uncompressed public key, hex encoded with the 0x04 prefix, as accounts are derived from.
+func PublicKeyHex(key *ecdsa.PrivateKey) string {
+	return fmt.Sprintf("0x%x", crypto.FromECDSAPub(&key.PublicKey))
+}
+
+func newKeyStore(dataDir string) *keystore.KeyStore {
+	return keystore.NewKeyStore(GetKeystoreDirPath(dataDir), keystore.StandardScryptN, keystore.StandardScryptP)
+}
@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"testing"
+	"the-blockchain-bar/utils"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestKeystoreAccounts(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "wallet_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(dataDir)
+
+	otherDataDir, err := ioutil.TempDir("", "wallet_test")
+	require.NoError(t, err)
+	defer utils.RemoveDir(otherDataDir)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	expected := crypto.PubkeyToAddress(key.PublicKey)
+
+	// Import a raw private key
+	account, err := ImportPrivateKey(dataDir, hexutil.Encode(crypto.FromECDSA(key)), testKeystoreAccountsPwd)
+	require.NoError(t, err)
+	assert.Equal(t, expected, account)
+	assert.Equal(t, expected, ListKeystoreAccounts(dataDir)[0])
+
+	_, err = ImportPrivateKey(dataDir, "not a key", testKeystoreAccountsPwd)
+	assert.Error(t, err)
+
+	// Change its password
+	require.NoError(t, ChangeKeystorePassword(dataDir, account, testKeystoreAccountsPwd, "new-password"))
+	_, err = DecryptKeystoreAccount(account, testKeystoreAccountsPwd, GetKeystoreDirPath(dataDir))
+	assert.Error(t, err)
+
+	decrypted, err := DecryptKeystoreAccount(account, "new-password", GetKeystoreDirPath(dataDir))
+	require.NoError(t, err)
+	assert.Equal(t, PublicKeyHex(key), PublicKeyHex(decrypted.PrivateKey))
+
+	// Export it and import the keystore JSON into another data dir
+	keyJson, err := ExportKeystoreAccount(dataDir, account, "new-password", "export-password")
+	require.NoError(t, err)
+
+	imported, err := ImportKeystore(otherDataDir, keyJson, "export-password", testKeystoreAccountsPwd)
+	require.NoError(t, err)
+	assert.Equal(t, account, imported)
+
+	_, err = DecryptKeystoreAccount(account, testKeystoreAccountsPwd, GetKeystoreDirPath(otherDataDir))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"the-blockchain-bar/database"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignMultisigTx adds the owner's signature to the multisig TX. The owners sign one after another, or each their
+// own copy of the TX, later combined with CombineMultisigTxs, until the TX has the threshold of signatures.
+func SignMultisigTx(tx database.SignedTx, key *ecdsa.PrivateKey) (database.SignedTx, error) {
+	if !tx.IsMultisig() {
+		return database.SignedTx{}, fmt.Errorf("%w. the TX isn't sent by a multisig account", database.ErrInvalidMultisig)
+	}
+
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	if !tx.Multisig.IsOwner(owner) {
+		return database.SignedTx{}, fmt.Errorf("%w. '%s' isn't an owner of the multisig account '%s'", database.ErrInvalidMultisig, owner.String(), tx.From.String())
+	}
+
+	signers, err := tx.MultisigSigners()
+	if err != nil {
+		return database.SignedTx{}, err
+	}
+
+	for _, signer := range signers {
+		if signer == owner {
+			return tx, nil
+		}
+	}
+
+	rawTx, err := tx.Tx.Encode()
+	if err != nil {
+		return database.SignedTx{}, err
+	}
+
+	sig, err := Sign(rawTx, key)
+	if err != nil {
+		return database.SignedTx{}, err
+	}
+
+	return withMultisigSigs(tx, append(tx.Multisig.Sigs, sig)), nil
+}
+
+// CombineMultisigTxs merges the signatures of copies of the same multisig TX, signed by different owners.
+func CombineMultisigTxs(txs ...database.SignedTx) (database.SignedTx, error) {
+	if len(txs) == 0 {
+		return database.SignedTx{}, fmt.Errorf("%w. no TX to combine", database.ErrInvalidMultisig)
+	}
+
+	combined := txs[0]
+	if !combined.IsMultisig() {
+		return database.SignedTx{}, fmt.Errorf("%w. the TX isn't sent by a multisig account", database.ErrInvalidMultisig)
+	}
+
+	txHash, err := combined.Tx.Hash()
+	if err != nil {
+		return database.SignedTx{}, err
+	}
+
+	sigs := make([][]byte, 0)
+	signed := make(map[common.Address]bool)
+
+	for _, tx := range txs {
+		if !tx.IsMultisig() {
+			return database.SignedTx{}, fmt.Errorf("%w. the TX isn't sent by a multisig account", database.ErrInvalidMultisig)
+		}
+
+		hash, err := tx.Tx.Hash()
+		if err != nil {
+			return database.SignedTx{}, err
+		}
+
+		if hash != txHash {
+			return database.SignedTx{}, fmt.Errorf("%w. can't combine the signatures of different TXs '%x' and '%x'", database.ErrInvalidMultisig, txHash, hash)
+		}
+
+		signers, err := tx.MultisigSigners()
+		if err != nil {
+			return database.SignedTx{}, err
+		}
+
+		for i, signer := range signers {
+			if signed[signer] {
+				continue
+			}
+
+			signed[signer] = true
+			sigs = append(sigs, tx.Multisig.Sigs[i])
+		}
+	}
+
+	return withMultisigSigs(combined, sigs), nil
+}
+
+// withMultisigSigs returns a copy of the TX with the signatures, leaving the signatures of the TX as they are.
+func withMultisigSigs(tx database.SignedTx, sigs [][]byte) database.SignedTx {
+	multisig := *tx.Multisig
+	multisig.Sigs = make([][]byte, len(sigs))
+	copy(multisig.Sigs, sigs)
+
+	tx.Multisig = &multisig
+
+	return tx
+}
@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+	"the-blockchain-bar/database"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/test-go/testify/require"
+)
+
+func TestSignMultisigTx(t *testing.T) {
+	owner1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	owner2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	outsider, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	policy, err := database.NewMultisigPolicy(2, []common.Address{
+		crypto.PubkeyToAddress(owner1.PublicKey),
+		crypto.PubkeyToAddress(owner2.PublicKey),
+	})
+	require.NoError(t, err)
+
+	tx, err := database.NewMultisigTx(database.NewBaseTx(policy.Address(), database.NewAccount(AndrejAccount), big.NewInt(100), 1, ""), policy)
+	require.NoError(t, err)
+
+	_, err = SignMultisigTx(tx, outsider)
+	assert.ErrorIs(t, err, database.ErrInvalidMultisig)
+
+	// Signed one after another
+	signed, err := SignMultisigTx(tx, owner1)
+	require.NoError(t, err)
+	assert.Empty(t, tx.Multisig.Sigs)
+
+	again, err := SignMultisigTx(signed, owner1)
+	require.NoError(t, err)
+	assert.Len(t, again.Multisig.Sigs, 1)
+
+	isAuthentic, err := signed.IsAuthentic()
+	require.NoError(t, err)
+	assert.False(t, isAuthentic)
+
+	signed, err = SignMultisigTx(signed, owner2)
+	require.NoError(t, err)
+
+	isAuthentic, err = signed.IsAuthentic()
+	require.NoError(t, err)
+	assert.True(t, isAuthentic)
+
+	// Or each their own copy, combined
+	signedBy1, err := SignMultisigTx(tx, owner1)
+	require.NoError(t, err)
+	signedBy2, err := SignMultisigTx(tx, owner2)
+	require.NoError(t, err)
+
+	combined, err := CombineMultisigTxs(signedBy1, signedBy2, signedBy1)
+	require.NoError(t, err)
+	assert.Len(t, combined.Multisig.Sigs, 2)
+
+	isAuthentic, err = combined.IsAuthentic()
+	require.NoError(t, err)
+	assert.True(t, isAuthentic)
+
+	other, err := database.NewMultisigTx(database.NewBaseTx(policy.Address(), database.NewAccount(AndrejAccount), big.NewInt(200), 1, ""), policy)
+	require.NoError(t, err)
+	_, err = CombineMultisigTxs(signedBy1, other)
+	assert.ErrorIs(t, err, database.ErrInvalidMultisig)
+}
@@ -3,6 +3,8 @@ package wallet
 import (
 	"crypto/ecdsa"
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"the-blockchain-bar/database"
@@ -26,8 +28,7 @@ func GetKeystoreDirPath(dataDir string) string {
 }
 
 func NewKeystoreAccount(dataDir, password string) (common.Address, error) {
-	ks := keystore.NewKeyStore(GetKeystoreDirPath(dataDir), keystore.StandardScryptN, keystore.StandardScryptP)
-	account, err := ks.NewAccount(password)
+	account, err := newKeyStore(dataDir).NewAccount(password)
 	if err != nil {
 		return common.Address{}, err
 	}
@@ -35,7 +36,28 @@ func NewKeystoreAccount(dataDir, password string) (common.Address, error) {
 	return account.Address, nil
 }
 
+// ErrMissingChainID is returned when signing a TX that would be valid on every network with the same accounts.
+var ErrMissingChainID = errors.New("the TX chain ID is required to sign it, so it can't be replayed on another network")
+
+// SignTx signs the TX for the network of its chain ID, from the TIP-8 fork on.
 func SignTx(tx database.Tx, key *ecdsa.PrivateKey) (database.SignedTx, error) {
+	if tx.ChainID == "" {
+		return database.SignedTx{}, ErrMissingChainID
+	}
+
+	return signTx(tx, key)
+}
+
+// SignLegacyTx signs a TX without chain ID, for networks prior to the TIP-8 fork.
+func SignLegacyTx(tx database.Tx, key *ecdsa.PrivateKey) (database.SignedTx, error) {
+	if tx.ChainID != "" {
+		return database.SignedTx{}, fmt.Errorf("a legacy TX can't have a chain ID, '%s' given", tx.ChainID)
+	}
+
+	return signTx(tx, key)
+}
+
+func signTx(tx database.Tx, key *ecdsa.PrivateKey) (database.SignedTx, error) {
 	rawTx, err := tx.Encode()
 	if err != nil {
 		return database.SignedTx{}, err
@@ -50,28 +72,33 @@ func SignTx(tx database.Tx, key *ecdsa.PrivateKey) (database.SignedTx, error) {
 }
 
 func SignTxWithKeystoreAccount(tx database.Tx, account common.Address, password, keystoreDir string) (database.SignedTx, error) {
-	ks := keystore.NewKeyStore(keystoreDir, keystore.StandardScryptN, keystore.StandardScryptP)
-	ksAccount, err := ks.Find(accounts.Account{Address: account})
+	key, err := DecryptKeystoreAccount(account, password, keystoreDir)
 	if err != nil {
 		return database.SignedTx{}, err
 	}
 
-	ksAccountJson, err := ioutil.ReadFile(ksAccount.URL.Path)
+	signedTx, err := SignTx(tx, key.PrivateKey)
 	if err != nil {
 		return database.SignedTx{}, err
 	}
 
-	key, err := keystore.DecryptKey(ksAccountJson, password)
+	return signedTx, nil
+}
+
+// DecryptKeystoreAccount reads the account private key from its keystore file.
+func DecryptKeystoreAccount(account common.Address, password, keystoreDir string) (*keystore.Key, error) {
+	ks := keystore.NewKeyStore(keystoreDir, keystore.StandardScryptN, keystore.StandardScryptP)
+	ksAccount, err := ks.Find(accounts.Account{Address: account})
 	if err != nil {
-		return database.SignedTx{}, err
+		return nil, err
 	}
 
-	signedTx, err := SignTx(tx, key.PrivateKey)
+	ksAccountJson, err := ioutil.ReadFile(ksAccount.URL.Path)
 	if err != nil {
-		return database.SignedTx{}, err
+		return nil, err
 	}
 
-	return signedTx, nil
+	return keystore.DecryptKey(ksAccountJson, password)
 }
 
 func NewRandomKey() (*keystore.Key, error) {
@@ -3,6 +3,7 @@ package wallet
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math/big"
 	"testing"
 	"the-blockchain-bar/database"
 	"the-blockchain-bar/utils"
@@ -18,6 +19,7 @@ import (
 // 	./resources/test_andrej--3eb92807f1f91a8d4d85bc908c7f86dcddb1df57
 // 	./resources/test_babayaga--6fdc0d8d15ae6b4ebf45c52fd2aafbcbb19a65c8
 const testKeystoreAccountsPwd = "security123"
+const testChainID = "the-blockchain-bar-test"
 
 func TestSignTxWithKeystoreAccount(t *testing.T) {
 	tmpDir, err := ioutil.TempDir("", "wallet_test")
@@ -31,7 +33,8 @@ func TestSignTxWithKeystoreAccount(t *testing.T) {
 	babayaga, err := NewKeystoreAccount(tmpDir, testKeystoreAccountsPwd)
 	assert.NoError(t, err)
 
-	tx := database.NewBaseTx(andrej, babayaga, 100, 1, "")
+	tx := database.NewBaseTx(andrej, babayaga, big.NewInt(100), 1, "")
+	tx.ChainID = testChainID
 
 	signedTx, err := SignTxWithKeystoreAccount(tx, andrej, testKeystoreAccountsPwd, GetKeystoreDirPath(tmpDir))
 	assert.NoError(t, err)
@@ -77,7 +80,8 @@ func TestSignForgedTxWithKeystoreAccount(t *testing.T) {
 	babayaga, err := NewKeystoreAccount(tmpDir, testKeystoreAccountsPwd)
 	assert.NoError(t, err)
 
-	forgedTx := database.NewBaseTx(babayaga, hacker, 100, 1, "")
+	forgedTx := database.NewBaseTx(babayaga, hacker, big.NewInt(100), 1, "")
+	forgedTx.ChainID = testChainID
 
 	signedTx, err := SignTxWithKeystoreAccount(forgedTx, hacker, testKeystoreAccountsPwd, GetKeystoreDirPath(tmpDir))
 	assert.NoError(t, err)
@@ -89,3 +93,29 @@ func TestSignForgedTxWithKeystoreAccount(t *testing.T) {
 		t.Fatal("the TX 'from' attribute was forged and should have not be authentic")
 	}
 }
+
+func TestSignTxRequiresChainID(t *testing.T) {
+	key, err := NewRandomKey()
+	require.NoError(t, err)
+
+	tx := database.NewBaseTx(key.Address, database.NewAccount(AndrejAccount), big.NewInt(100), 1, "")
+
+	_, err = SignTx(tx, key.PrivateKey)
+	require.Equal(t, ErrMissingChainID, err)
+
+	// Networks prior to the TIP-8 fork sign TXs without chain ID explicitly
+	signedTx, err := SignLegacyTx(tx, key.PrivateKey)
+	require.NoError(t, err)
+
+	ok, err := signedTx.IsAuthentic()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	tx.ChainID = testChainID
+	_, err = SignLegacyTx(tx, key.PrivateKey)
+	require.Error(t, err)
+
+	signedTx, err = SignTx(tx, key.PrivateKey)
+	require.NoError(t, err)
+	require.Equal(t, testChainID, signedTx.ChainID)
+}